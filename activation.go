@@ -56,6 +56,28 @@ func ReluBackward(activation float64) float64 {
 	return 1.0
 }
 
+// Constants of selu (self-normalizing)
+const (
+	seluAlpha = 1.6732632423543772
+	seluScale = 1.0507009873554805
+)
+
+// SeluForward is the selu fn
+func SeluForward(sum float64) float64 {
+	if sum > 0.0 {
+		return seluScale * sum
+	}
+	return seluScale * seluAlpha * (math.Exp(sum) - 1.0)
+}
+
+// SeluBackward is the selu derivative
+func SeluBackward(activation float64) float64 {
+	if activation > 0.0 {
+		return seluScale
+	}
+	return activation + seluScale*seluAlpha
+}
+
 // ActivationSet is a forward and backward fn with its range
 type ActivationSet struct {
 	Forward  ForwardFn
@@ -82,6 +104,9 @@ func selectActivation(activation string) ActivationSet {
 		set.Forward = ReluForward
 		set.Backward = ReluBackward
 		set.Ranges = []float64{0.0, 1.0}
+	} else if activation == "selu" {
+		set.Forward = SeluForward
+		set.Backward = SeluBackward
 	} else {
 		panic("need a valid activation name")
 	}
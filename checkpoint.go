@@ -0,0 +1,184 @@
+package neural
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+)
+
+const checkpointFile = "checkpoint.json"
+
+// Checkpoint is the state of an evolution run so it can be resumed later
+type Checkpoint struct {
+	// Next epoch to run
+	Epoch int `json:"Epoch"`
+	// State of the random source (only when neural is seeded)
+	Random *Random `json:"Random,omitempty"`
+	// Best and mean loss of the population for every epoch
+	Best []float64 `json:"Best"`
+	Mean []float64 `json:"Mean"`
+	// Every individual using the neural Export format
+	Population []json.RawMessage `json:"Population"`
+	// Training state of every individual (in the same order)
+	Training []Training `json:"Training,omitempty"`
+}
+
+// Training is the state of an individual that is not exported but changes how it keeps learning
+type Training struct {
+	// Momentum of every param (in the order of Params)
+	Momentums []float64 `json:"Momentums"`
+	// Rate of every layer and its own rate before scheduling (zero if it wasn't scheduled yet)
+	Rates     []float64 `json:"Rates"`
+	BaseRates []float64 `json:"BaseRates"`
+	// Amount of learned samples and epochs
+	Steps  int `json:"Steps"`
+	Epochs int `json:"Epochs"`
+	// State of the scheduler (see SchedulerState)
+	Scheduler []float64 `json:"Scheduler,omitempty"`
+}
+
+// NewCheckpoint creates a checkpoint from a population
+func NewCheckpoint(population []*Neural, epoch int) (*Checkpoint, error) {
+	checkpoint := &Checkpoint{}
+	return checkpoint, checkpoint.Update(population, epoch)
+}
+
+// Update exports the population and the run state into the checkpoint
+func (checkpoint *Checkpoint) Update(population []*Neural, epoch int) error {
+	checkpoint.Epoch = epoch
+	checkpoint.Random = nil
+	checkpoint.Population = make([]json.RawMessage, len(population))
+	checkpoint.Training = make([]Training, len(population))
+
+	if len(population) > 0 && population[0].Random != nil {
+		checkpoint.Random = &Random{State: population[0].Random.State}
+	}
+
+	for p, individual := range population {
+		encoded, err := individual.Export()
+		if err != nil {
+			return err
+		}
+		checkpoint.Population[p] = encoded
+		checkpoint.Training[p] = individual.trainingState()
+	}
+
+	return nil
+}
+
+// Neurals imports the population over clones of neural (so rates and other layer settings are kept)
+// It also restores the state of the neural random source and the training state of every individual
+func (checkpoint *Checkpoint) Neurals(neural *Neural) ([]*Neural, error) {
+	if len(checkpoint.Population) == 0 {
+		return nil, errors.New("checkpoint without population")
+	}
+
+	population := make([]*Neural, len(checkpoint.Population))
+	for p, encoded := range checkpoint.Population {
+		population[p] = neural.Clone()
+
+		if err := population[p].Import(encoded); err != nil {
+			return nil, err
+		}
+		if p < len(checkpoint.Training) {
+			if err := population[p].setTrainingState(checkpoint.Training[p]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// cloning consumes random numbers so the state is restored at the end
+	if checkpoint.Random != nil && neural.Random != nil {
+		neural.Random.State = checkpoint.Random.State
+	}
+
+	return population, nil
+}
+
+// Export checkpoint to json string
+func (checkpoint *Checkpoint) Export() ([]byte, error) {
+	return json.Marshal(checkpoint)
+}
+
+// Import checkpoint from json string
+func (checkpoint *Checkpoint) Import(encoded []byte) error {
+	return json.Unmarshal(encoded, checkpoint)
+}
+
+// ToFile export checkpoint to file (written to a temporary file first so a crash can't corrupt it)
+func (checkpoint *Checkpoint) ToFile(filename string) error {
+	encoded, err := checkpoint.Export()
+	if err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(filename+".tmp", encoded, 0644); err != nil {
+		return err
+	}
+	return os.Rename(filename+".tmp", filename)
+}
+
+// FromFile import checkpoint from file
+func (checkpoint *Checkpoint) FromFile(filename string) error {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return checkpoint.Import(content)
+}
+
+// trainingState returns the training state of the neural
+func (neural *Neural) trainingState() Training {
+	training := Training{
+		Momentums: []float64{},
+		Rates:     make([]float64, neural.MaxLayers),
+		BaseRates: make([]float64, neural.MaxLayers),
+		Steps:     neural.steps,
+		Epochs:    neural.epochs,
+	}
+
+	for i := 0; i < neural.MaxLayers; i++ {
+		training.Rates[i] = neural.Layers[i].Rate
+		training.BaseRates[i] = neural.Layers[i].baseRate
+		for _, param := range neural.Layers[i].params(nil) {
+			training.Momentums = append(training.Momentums, *param.Momentum)
+		}
+	}
+
+	if scheduler, ok := neural.Scheduler.(SchedulerState); ok {
+		training.Scheduler = scheduler.State()
+	}
+
+	return training
+}
+
+// setTrainingState restores the training state of a neural with the same layers
+func (neural *Neural) setTrainingState(training Training) error {
+	if len(training.Momentums) != neural.NumParams() || len(training.Rates) != neural.MaxLayers || len(training.BaseRates) != neural.MaxLayers {
+		return errors.New("training state of a different neural")
+	}
+
+	m := 0
+	for i := 0; i < neural.MaxLayers; i++ {
+		neural.Layers[i].Rate = training.Rates[i]
+		neural.Layers[i].baseRate, neural.Layers[i].scheduledRate = training.BaseRates[i], training.Rates[i]
+		for _, param := range neural.Layers[i].params(nil) {
+			*param.Momentum = training.Momentums[m]
+			m++
+		}
+	}
+
+	neural.steps, neural.epochs = training.Steps, training.Epochs
+
+	if scheduler, ok := neural.Scheduler.(SchedulerState); ok && training.Scheduler != nil {
+		scheduler.SetState(training.Scheduler)
+	}
+
+	return nil
+}
+
+func fileExists(filename string) bool {
+	_, err := os.Stat(filename)
+	return err == nil
+}
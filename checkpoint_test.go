@@ -0,0 +1,142 @@
+package neural
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// tempDir creates a directory for the files of a test (remove it after)
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "neural")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestEvolutionSavesLastEpoch(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	neural := NewNeural([]*Layer{{Inputs: 2, Units: 2}, {Units: 1}})
+	neural.Seed(1)
+	neural.Reset()
+
+	evolve := Evolve{
+		Population: 6,
+		Elitism:    2,
+		Epochs:     3,
+		Dataset:    [][][]float64{{{0, 0}, {0}}, {{1, 1}, {1}}},
+		Checkpoint: dir,
+		// without periodic checkpoints only the last one is written
+		CheckpointEvery: 10,
+		Callback:        func(epoch int, loss float64) bool { return true },
+	}
+
+	best, err := neural.Evolution(evolve)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state := &Checkpoint{}
+	if err := state.FromFile(filepath.Join(dir, checkpointFile)); err != nil {
+		t.Fatal(err)
+	}
+	if state.Epoch != 3 || len(state.Best) != 3 || len(state.Population) != 6 {
+		t.Fatalf("checkpoint of epoch %v with %v losses and %v individuals", state.Epoch, len(state.Best), len(state.Population))
+	}
+
+	// resuming a finished run returns the saved best without learning again
+	evolve.Resume = true
+	resumed, err := neural.Evolution(evolve)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resumed.Think([]float64{1, 0})[0] != best.Think([]float64{1, 0})[0] {
+		t.Fatal("resumed best is different")
+	}
+}
+
+func TestEvolutionReturnsCheckpointErrors(t *testing.T) {
+	neural := NewNeural([]*Layer{{Inputs: 2, Units: 1}})
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "file")
+	if err := neural.ToFile(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := neural.Evolution(Evolve{
+		Epochs:     1,
+		Dataset:    [][][]float64{{{0, 0}, {0}}},
+		Checkpoint: filepath.Join(filename, "dir"),
+		Callback:   func(epoch int, loss float64) bool { return true },
+	})
+	if err == nil {
+		t.Fatal("expected an error creating the checkpoint directory")
+	}
+}
+
+func TestEvolutionResumesLikeContinuous(t *testing.T) {
+	template := func() *Neural {
+		neural := NewNeural([]*Layer{{Inputs: 2, Units: 3, Activation: "tanh"}, {Units: 1}})
+		neural.Seed(1)
+		neural.Reset()
+		neural.Scheduler = &ReduceOnPlateau{Patience: 1, Factor: 0.5}
+		return neural
+	}
+	evolve := func(dir string, callback func(epoch int, loss float64) bool) Evolve {
+		return Evolve{
+			Population: 6,
+			Elitism:    2,
+			Epochs:     6,
+			Iterations: 3,
+			Dataset:    [][][]float64{{{0, 0}, {0}}, {{0, 1}, {1}}, {{1, 0}, {1}}, {{1, 1}, {0}}},
+			Checkpoint: dir,
+			Resume:     true,
+			Callback:   callback,
+		}
+	}
+	next := func(epoch int, loss float64) bool { return true }
+
+	continuous := tempDir(t)
+	defer os.RemoveAll(continuous)
+	expected, err := template().Evolution(evolve(continuous, next))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the process dies in the middle of the fourth epoch, the checkpoint has the first three
+	interrupted := tempDir(t)
+	defer os.RemoveAll(interrupted)
+	func() {
+		defer func() { recover() }()
+		template().Evolution(evolve(interrupted, func(epoch int, loss float64) bool {
+			if epoch == 3 {
+				panic("killed")
+			}
+			return true
+		}))
+	}()
+
+	resumed, err := template().Evolution(evolve(interrupted, next))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(resumed.Params(), expected.Params()) || !reflect.DeepEqual(resumed.momentums(), expected.momentums()) {
+		t.Fatal("the resumed run learned a different best")
+	}
+	a, b := &Checkpoint{}, &Checkpoint{}
+	if err := a.FromFile(filepath.Join(continuous, checkpointFile)); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.FromFile(filepath.Join(interrupted, checkpointFile)); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(a, b) {
+		t.Fatal("the checkpoints of both runs are different")
+	}
+}
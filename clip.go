@@ -0,0 +1,59 @@
+package neural
+
+import (
+	"fmt"
+	"math"
+)
+
+// DivergedError aborts the training when the weights of a neuron blow up (NaN or Inf)
+type DivergedError struct {
+	Layer  int
+	Neuron int
+}
+
+func (err *DivergedError) Error() string {
+	return fmt.Sprintf("weights of layer %v neuron %v are not finite", err.Layer, err.Neuron)
+}
+
+// clip the gradients of all trainable layers by value and then by global norm
+func (neural *Neural) clip() {
+	if neural.ClipValue > 0.0 {
+		for i := 0; i < neural.MaxLayers; i++ {
+			if neural.Layers[i].Frozen {
+				continue
+			}
+			for _, param := range neural.Layers[i].params(nil) {
+				*param.Gradient = math.Max(-neural.ClipValue, math.Min(neural.ClipValue, *param.Gradient))
+			}
+		}
+	}
+
+	if neural.ClipNorm > 0.0 {
+		norm := 0.0
+		for i := 0; i < neural.MaxLayers; i++ {
+			if neural.Layers[i].Frozen {
+				continue
+			}
+			for _, param := range neural.Layers[i].params(nil) {
+				norm += *param.Gradient * *param.Gradient
+			}
+		}
+		norm = math.Sqrt(norm)
+
+		if norm > neural.ClipNorm {
+			scale := neural.ClipNorm / norm
+			for i := 0; i < neural.MaxLayers; i++ {
+				if neural.Layers[i].Frozen {
+					continue
+				}
+				for _, param := range neural.Layers[i].params(nil) {
+					*param.Gradient *= scale
+				}
+			}
+		}
+	}
+}
+
+func isFinite(value float64) bool {
+	return !math.IsNaN(value) && !math.IsInf(value, 0)
+}
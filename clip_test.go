@@ -0,0 +1,110 @@
+package neural
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+// assignGrads sets the gradients of all layers in the order of Params
+func (neural *Neural) assignGrads(grads []float64) {
+	g := 0
+	for i := 0; i < neural.MaxLayers; i++ {
+		for _, neuron := range neural.Layers[i].Neurons {
+			g += copy(neuron.Gradients, grads[g:])
+		}
+	}
+}
+
+// momentums of every param in the order of Params
+func (neural *Neural) momentums() []float64 {
+	momentums := []float64{}
+	for i := 0; i < neural.MaxLayers; i++ {
+		for _, neuron := range neural.Layers[i].Neurons {
+			momentums = append(momentums, neuron.Momentums...)
+		}
+	}
+	return momentums
+}
+
+func TestClipValue(t *testing.T) {
+	neural := NewNeural([]*Layer{{Inputs: 1, Units: 1}, {Units: 1}})
+	neural.ClipValue = 0.5
+	neural.assignGrads([]float64{3, -0.2, -7, 0.4})
+
+	neural.clip()
+	if grads := neural.Grads(); !reflect.DeepEqual(grads, []float64{0.5, -0.2, -0.5, 0.4}) {
+		t.Fatalf("clipped gradients %v", grads)
+	}
+}
+
+func TestClipNorm(t *testing.T) {
+	neural := NewNeural([]*Layer{{Inputs: 1, Units: 1}, {Units: 1}})
+	neural.ClipNorm = 1
+
+	// the global norm is 5 across both layers, so every gradient is scaled by 1/5
+	neural.assignGrads([]float64{3, 0, 0, 4})
+	neural.clip()
+	if grads := neural.Grads(); math.Abs(grads[0]-0.6) > 1e-12 || math.Abs(grads[3]-0.8) > 1e-12 {
+		t.Fatalf("clipped gradients %v", grads)
+	}
+
+	// a smaller norm is kept
+	neural.assignGrads([]float64{0.3, 0, 0, 0.4})
+	neural.clip()
+	if grads := neural.Grads(); grads[0] != 0.3 || grads[3] != 0.4 {
+		t.Fatalf("gradients under the norm changed %v", grads)
+	}
+}
+
+func TestUpdateIsAtomic(t *testing.T) {
+	neural := NewNeural([]*Layer{{Inputs: 2, Units: 3}, {Units: 2}, {Units: 1}})
+	neural.Seed(1)
+	neural.Reset()
+	neural.LearnRaw([]float64{0.5, -0.5}, []float64{1})
+
+	params, momentums := neural.Params(), neural.momentums()
+
+	// only the last neuron diverges, so the first layers would be updated if the update wasn't atomic
+	grads := make([]float64, neural.NumParams())
+	for g := range grads {
+		grads[g] = 0.1
+	}
+	grads[len(grads)-2] = math.Inf(1)
+	neural.assignGrads(grads)
+
+	err := neural.update()
+	diverged, ok := err.(*DivergedError)
+	if !ok || diverged.Layer != 2 || diverged.Neuron != 0 {
+		t.Fatalf("expected a diverged error of layer 2 neuron 0, got %v", err)
+	}
+	if !reflect.DeepEqual(neural.Params(), params) || !reflect.DeepEqual(neural.momentums(), momentums) {
+		t.Fatal("the update changed params or momentums")
+	}
+}
+
+func TestDivergedAbortsTraining(t *testing.T) {
+	neural := NewNeural([]*Layer{{Inputs: 1, Units: 2, Activation: "relu", Rate: 1e200}, {Units: 1, Activation: "linear", Rate: 1e200}})
+	neural.Seed(1)
+	neural.Reset()
+
+	loss := neural.LearnsRaw([][][]float64{{{1e200}, {-1e200}}, {{1}, {1}}, {{2}, {2}}})
+	if _, ok := neural.Err().(*DivergedError); !ok || !math.IsNaN(loss) {
+		t.Fatalf("expected a diverged error and a NaN loss, got %v and %v", neural.Err(), loss)
+	}
+	for _, param := range neural.Params() {
+		if !isFinite(param) {
+			t.Fatal("kept a param that is not finite")
+		}
+	}
+
+	// it doesn't learn until the error is cleared
+	params := neural.Params()
+	if loss := neural.LearnRaw([]float64{1}, []float64{1}); !math.IsNaN(loss) || !reflect.DeepEqual(neural.Params(), params) {
+		t.Fatal("learned after the training was aborted")
+	}
+	neural.ClearErr()
+	if loss := neural.LearnRaw([]float64{0}, []float64{0}); math.IsNaN(loss) {
+		t.Fatal("didn't learn after clearing the error")
+	}
+}
@@ -0,0 +1,294 @@
+package neural
+
+import (
+	"encoding/json"
+	"math"
+)
+
+// Spatial layers see the flat inputs as channels of height * width values (channel by channel, row by row)
+// conv2d has a neuron per filter with weights for channels * kernel * kernel values (conv1d uses a height of 1),
+// maxpool and avgpool reduce every window of every channel, and flatten only marks the end of the spatial layers.
+// Windows move by Stride, the inputs are padded with Padding zeros and Dilation spaces the values of a window
+
+// spatialState is what a spatial forward keeps for the backward
+type spatialState struct {
+	inputs  []float64
+	outputs []float64
+	argmax  []int
+}
+
+// spatialKind keeps the inputs and outputs of the last forward, and the input chosen by every output of maxpool
+type spatialKind struct {
+	NeuronKind
+	inputs  []float64
+	outputs []float64
+	argmax  []int
+}
+
+func (layer *Layer) isSpatial() bool {
+	switch layer.Type {
+	case "conv1d", "conv2d", "maxpool", "avgpool", "flatten":
+		return true
+	}
+	return false
+}
+
+// outputShape is the channels, height and width of the outputs (dense layers are channels of 1x1)
+func (layer *Layer) outputShape() (int, int, int) {
+	switch layer.Type {
+	case "conv1d", "conv2d":
+		return layer.Filters, layer.outputHeight(), layer.outputWidth()
+	case "maxpool", "avgpool":
+		return layer.Channels, layer.outputHeight(), layer.outputWidth()
+	}
+	return layer.Units, 1, 1
+}
+
+// inheritShape takes the shape of the inputs (or dimensions of the tokens) from the previous layer when it's not defined
+func (layer *Layer) inheritShape(previous *Layer) {
+	if layer.isTransformer() && layer.Dimensions == 0 && previous != nil {
+		layer.Dimensions = previous.Dimensions
+	}
+	if !layer.isSpatial() || layer.Channels > 0 || previous == nil {
+		return
+	}
+	layer.Channels, layer.Height, layer.Width = previous.outputShape()
+}
+
+// shapeInputs is the amount of inputs of the shape (conv1d defaults to a height of 1)
+func (layer *Layer) shapeInputs() int {
+	if layer.Type == "conv1d" && layer.Height == 0 {
+		layer.Height = 1
+	}
+	return layer.Channels * layer.Height * layer.Width
+}
+
+// New checks the shape and creates a neuron per filter (default activation is relu, linear for pooling)
+func (*spatialKind) New(layer *Layer) {
+	if layer.Type == "flatten" {
+		layer.Units = layer.Inputs
+		layer.Activation = "linear"
+		return
+	}
+
+	if layer.Inputs == 0 {
+		layer.Inputs = layer.shapeInputs()
+	}
+	if layer.Type == "conv1d" && layer.Height != 1 {
+		panic("need a height of 1 in conv1d layers")
+	}
+	if layer.Channels == 0 || layer.Inputs != layer.shapeInputs() {
+		panic("need inputs of channels * height * width")
+	}
+	if layer.Kernel == 0 {
+		panic("need a kernel size")
+	}
+
+	if layer.Stride == 0 {
+		layer.Stride = 1
+		if layer.Type == "maxpool" || layer.Type == "avgpool" {
+			layer.Stride = layer.Kernel
+		}
+	}
+	if layer.Dilation == 0 {
+		layer.Dilation = 1
+	}
+	if layer.outputHeight() < 1 || layer.outputWidth() < 1 {
+		panic("need a kernel that fits in the inputs")
+	}
+
+	if layer.Type == "maxpool" || layer.Type == "avgpool" {
+		layer.Activation = "linear"
+		layer.Units = layer.Channels * layer.outputHeight() * layer.outputWidth()
+		return
+	}
+
+	if layer.Filters == 0 {
+		panic("need filters in convolution layers")
+	}
+	if layer.Activation == "" {
+		layer.Activation = "relu"
+	}
+
+	layer.Units = layer.Filters * layer.outputHeight() * layer.outputWidth()
+	layer.Neurons = make([]*Neuron, layer.Filters)
+	for i := range layer.Neurons {
+		layer.Neurons[i] = NewNeuron(layer, layer.Channels*layer.kernelHeight()*layer.Kernel)
+	}
+}
+
+// Import sets the inputs and units of an imported spatial layer from its shape
+func (kind *spatialKind) Import(layer *Layer, data json.RawMessage) error {
+	kind.NeuronKind.Import(layer, data)
+	layer.Inputs = layer.shapeInputs()
+
+	channels, height, width := layer.outputShape()
+	layer.Units = channels * height * width
+	if layer.Type == "flatten" {
+		layer.Units = layer.Inputs
+	}
+	return nil
+}
+
+func (kind *spatialKind) State(layer *Layer) interface{} {
+	return spatialState{append([]float64(nil), kind.inputs...), append([]float64(nil), kind.outputs...), kind.argmax}
+}
+
+func (kind *spatialKind) SetState(layer *Layer, state interface{}) {
+	spatial := state.(spatialState)
+	kind.inputs, kind.outputs, kind.argmax = spatial.inputs, spatial.outputs, spatial.argmax
+}
+
+// kernelHeight is the kernel size in height (1 for one dimensional inputs)
+func (layer *Layer) kernelHeight() int {
+	if layer.Height == 1 {
+		return 1
+	}
+	return layer.Kernel
+}
+
+func (layer *Layer) paddingHeight() int {
+	if layer.Height == 1 {
+		return 0
+	}
+	return layer.Padding
+}
+
+func (layer *Layer) outputHeight() int {
+	return convolutionSize(layer.Height, layer.kernelHeight(), layer.Stride, layer.paddingHeight(), layer.Dilation)
+}
+
+func (layer *Layer) outputWidth() int {
+	return convolutionSize(layer.Width, layer.Kernel, layer.Stride, layer.Padding, layer.Dilation)
+}
+
+func convolutionSize(size int, kernel int, stride int, padding int, dilation int) int {
+	return (size+2*padding-dilation*(kernel-1)-1)/stride + 1
+}
+
+// window calls fn with the offset in the kernel and the input index (of channel 0) of every value inside the inputs
+// for the window of an output position
+func (layer *Layer) window(y int, x int, fn func(k int, index int)) {
+	kernelHeight := layer.kernelHeight()
+
+	for ky := 0; ky < kernelHeight; ky++ {
+		iy := y*layer.Stride - layer.paddingHeight() + ky*layer.Dilation
+		if iy < 0 || iy >= layer.Height {
+			continue
+		}
+		for kx := 0; kx < layer.Kernel; kx++ {
+			ix := x*layer.Stride - layer.Padding + kx*layer.Dilation
+			if ix < 0 || ix >= layer.Width {
+				continue
+			}
+			fn(ky*layer.Kernel+kx, iy*layer.Width+ix)
+		}
+	}
+}
+
+// Forward process the spatial layer
+func (kind *spatialKind) Forward(layer *Layer, inputs []float64, outs []float64, training bool) {
+	kind.inputs = append(kind.inputs[:0], inputs[:layer.Inputs]...)
+
+	if layer.Type == "flatten" {
+		copy(outs, inputs)
+		return
+	}
+
+	area := layer.Height * layer.Width
+	kernelArea := layer.kernelHeight() * layer.Kernel
+	height, width := layer.outputHeight(), layer.outputWidth()
+
+	if layer.Type == "maxpool" {
+		kind.argmax = make([]int, len(outs))
+	}
+
+	for c := 0; c < len(outs)/(height*width); c++ {
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				o := (c*height+y)*width + x
+
+				switch layer.Type {
+				case "maxpool":
+					outs[o], kind.argmax[o] = math.Inf(-1), -1
+					layer.window(y, x, func(k int, index int) {
+						if value := inputs[c*area+index]; value > outs[o] {
+							outs[o], kind.argmax[o] = value, c*area+index
+						}
+					})
+					if kind.argmax[o] == -1 {
+						outs[o] = 0.0
+					}
+				case "avgpool":
+					count := 0
+					layer.window(y, x, func(k int, index int) {
+						outs[o] += inputs[c*area+index]
+						count++
+					})
+					if count > 0 {
+						outs[o] /= float64(count)
+					}
+				default:
+					neuron := layer.Neurons[c]
+					sum := neuron.Bias
+					layer.window(y, x, func(k int, index int) {
+						for channel := 0; channel < layer.Channels; channel++ {
+							sum += inputs[channel*area+index] * neuron.Weights[channel*kernelArea+k]
+						}
+					})
+					outs[o] = layer.Forward(sum)
+				}
+			}
+		}
+	}
+
+	kind.outputs = append(kind.outputs[:0], outs...)
+}
+
+// Backward adds the gradients of the filters and returns the errors of the inputs
+func (kind *spatialKind) Backward(layer *Layer, errors []float64) []float64 {
+	inputErrors := make([]float64, layer.Inputs)
+
+	if layer.Type == "flatten" {
+		copy(inputErrors, errors)
+		return inputErrors
+	}
+
+	area := layer.Height * layer.Width
+	kernelArea := layer.kernelHeight() * layer.Kernel
+	height, width := layer.outputHeight(), layer.outputWidth()
+
+	for c := 0; c < len(errors)/(height*width); c++ {
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				o := (c*height+y)*width + x
+
+				switch layer.Type {
+				case "maxpool":
+					if kind.argmax[o] >= 0 {
+						inputErrors[kind.argmax[o]] += errors[o]
+					}
+				case "avgpool":
+					count := 0
+					layer.window(y, x, func(k int, index int) { count++ })
+					layer.window(y, x, func(k int, index int) {
+						inputErrors[c*area+index] += errors[o] / float64(count)
+					})
+				default:
+					neuron := layer.Neurons[c]
+					delta := layer.Backward(kind.outputs[o]) * errors[o]
+					layer.window(y, x, func(k int, index int) {
+						for channel := 0; channel < layer.Channels; channel++ {
+							w := channel*kernelArea + k
+							inputErrors[channel*area+index] += neuron.Weights[w] * delta
+							neuron.Gradients[w] -= kind.inputs[channel*area+index] * delta
+						}
+					})
+					neuron.Gradients[neuron.MaxInputs] -= delta
+				}
+			}
+		}
+	}
+
+	return inputErrors
+}
@@ -0,0 +1,88 @@
+package neural
+
+import (
+	"reflect"
+	"testing"
+)
+
+// spatialTests are spatial layers over inputs of different shapes, with strides, padding, dilation and pooling
+var spatialTests = []struct {
+	name   string
+	inputs int
+	layers []*Layer
+}{
+	{"conv1d", 16, []*Layer{
+		{Type: "conv1d", Channels: 2, Width: 8, Filters: 3, Kernel: 3, Activation: "tanh"},
+		{Type: "flatten"},
+	}},
+	{"conv1d/stride/padding/dilation", 16, []*Layer{
+		{Type: "conv1d", Channels: 2, Width: 8, Filters: 3, Kernel: 3, Stride: 2, Padding: 1, Dilation: 2, Activation: "tanh"},
+		{Type: "flatten"},
+	}},
+	{"conv2d", 50, []*Layer{
+		{Type: "conv2d", Channels: 2, Height: 5, Width: 5, Filters: 3, Kernel: 3, Padding: 1, Activation: "tanh"},
+		{Type: "flatten"},
+	}},
+	{"conv2d/stride/padding/dilation", 72, []*Layer{
+		{Type: "conv2d", Channels: 2, Height: 6, Width: 6, Filters: 2, Kernel: 3, Stride: 2, Padding: 1, Dilation: 2, Activation: "tanh"},
+		{Type: "flatten"},
+	}},
+	{"maxpool", 50, []*Layer{
+		{Type: "conv2d", Channels: 2, Height: 5, Width: 5, Filters: 3, Kernel: 2, Activation: "tanh"},
+		{Type: "maxpool", Kernel: 2},
+		{Type: "flatten"},
+	}},
+	{"avgpool", 50, []*Layer{
+		{Type: "conv2d", Channels: 2, Height: 5, Width: 5, Filters: 3, Kernel: 2, Activation: "tanh"},
+		{Type: "avgpool", Kernel: 2, Stride: 1, Padding: 1},
+		{Type: "flatten"},
+	}},
+}
+
+// spatialNeural ends the layers of a test with a dense output
+func spatialNeural(layers []*Layer) *Neural {
+	clones := make([]*Layer, len(layers))
+	for i, layer := range layers {
+		clones[i] = layer.definition()
+	}
+
+	neural := NewNeural(append(clones, &Layer{Units: 2}))
+	neural.Seed(1)
+	neural.Reset()
+	return neural
+}
+
+// spatialInputs are different values in (-1, 1)
+func spatialInputs(size int) []float64 {
+	inputs := make([]float64, size)
+	for i := range inputs {
+		inputs[i] = float64((i*37)%19-9) / 10
+	}
+	return inputs
+}
+
+func TestGradCheckSpatial(t *testing.T) {
+	for _, test := range spatialTests {
+		t.Run(test.name, func(t *testing.T) {
+			checkGradients(t, spatialNeural(test.layers), spatialInputs(test.inputs), []float64{0.3, 0.8})
+		})
+	}
+}
+
+func TestSpatialRoundTrip(t *testing.T) {
+	for _, test := range spatialTests {
+		t.Run(test.name, func(t *testing.T) {
+			neural := spatialNeural(test.layers)
+			neural.LearnRaw(spatialInputs(test.inputs), []float64{0.3, 0.8})
+			imported := roundTrip(t, neural)
+
+			if !reflect.DeepEqual(imported.Params(), neural.Params()) {
+				t.Fatal("params changed")
+			}
+			inputs := spatialInputs(test.inputs)
+			if !reflect.DeepEqual(imported.ThinkRaw(inputs), neural.ThinkRaw(inputs)) {
+				t.Fatal("thinks different after import")
+			}
+		})
+	}
+}
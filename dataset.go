@@ -0,0 +1,495 @@
+package neural
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Dataset is a table of values with named columns, missing values are NaN
+// Subsets (Split, Batches, KFold, etc) share the rows with the original dataset
+type Dataset struct {
+	Columns []string
+	Rows    [][]float64
+	// Vocabulary of categorical columns (declared by a schema), their values are indexes of the vocabulary
+	Categories map[string][]string
+	// Columns used as inputs (default is every column that is not a target)
+	Inputs []string
+	// Columns used as outputs
+	Targets []string
+}
+
+// Fold is a pair of datasets for cross-validation
+type Fold struct {
+	Train *Dataset
+	Test  *Dataset
+}
+
+// NewDataset creates a dataset from the inputs/outputs format (columns are named x0, x1, ..., y0, y1, ...)
+func NewDataset(samples [][][]float64) *Dataset {
+	dataset := &Dataset{}
+	if len(samples) == 0 {
+		return dataset
+	}
+
+	for i := range samples[0][0] {
+		dataset.Inputs = append(dataset.Inputs, "x"+strconv.Itoa(i))
+	}
+	for i := range samples[0][1] {
+		dataset.Targets = append(dataset.Targets, "y"+strconv.Itoa(i))
+	}
+	dataset.Columns = append(append([]string{}, dataset.Inputs...), dataset.Targets...)
+
+	for _, sample := range samples {
+		dataset.Rows = append(dataset.Rows, append(append([]float64{}, sample[0]...), sample[1]...))
+	}
+
+	return dataset
+}
+
+// ReadCSV reads a dataset with a header row, use ',' for CSV or '\t' for TSV
+func ReadCSV(r io.Reader, separator rune) (*Dataset, error) {
+	return readCSV(r, separator, nil)
+}
+
+func readCSV(r io.Reader, separator rune, schema *Schema) (*Dataset, error) {
+	reader := csv.NewReader(r)
+	reader.Comma = separator
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	// the header is the first line
+	return newDatasetFromRecords(header, records, schema, 2, "column")
+}
+
+// ReadJSONL reads a dataset of one json object per line, columns are the keys in order of appearance
+func ReadJSONL(r io.Reader) (*Dataset, error) {
+	return readJSONL(r, nil)
+}
+
+func readJSONL(r io.Reader, schema *Schema) (*Dataset, error) {
+	columns := []string{}
+	index := map[string]int{}
+	objects := []map[string]interface{}{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		keys, err := objectKeys(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %v: %v", len(objects)+1, err)
+		}
+		for _, key := range keys {
+			if _, ok := index[key]; !ok {
+				index[key] = len(columns)
+				columns = append(columns, key)
+			}
+		}
+
+		object := map[string]interface{}{}
+		if err := json.Unmarshal(line, &object); err != nil {
+			return nil, fmt.Errorf("line %v: %v", len(objects)+1, err)
+		}
+		objects = append(objects, object)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	records := make([][]string, len(objects))
+	for i, object := range objects {
+		records[i] = make([]string, len(columns))
+
+		for key, raw := range object {
+			field, err := jsonField(raw)
+			if err != nil {
+				return nil, fmt.Errorf("line %v key %v: %v", i+1, key, err)
+			}
+			records[i][index[key]] = field
+		}
+	}
+
+	return newDatasetFromRecords(columns, records, schema, 1, "key")
+}
+
+// newDatasetFromRecords converts text fields to values, first is the line of the first record
+// Categorical columns of the schema become indexes of their vocabulary, other columns need numbers
+func newDatasetFromRecords(columns []string, records [][]string, schema *Schema, first int, field string) (*Dataset, error) {
+	dataset := &Dataset{
+		Columns:    columns,
+		Rows:       make([][]float64, len(records)),
+		Categories: map[string][]string{},
+	}
+
+	indexes := make([]map[string]int, len(columns))
+	for c, column := range columns {
+		if vocabulary := schema.vocabulary(column); vocabulary != nil {
+			dataset.Categories[column] = append([]string{}, *vocabulary...)
+			indexes[c] = map[string]int{}
+			for i, word := range *vocabulary {
+				indexes[c][word] = i
+			}
+		}
+	}
+
+	for r, record := range records {
+		dataset.Rows[r] = make([]float64, len(columns))
+
+		for c, text := range record {
+			if indexes[c] == nil {
+				value, err := parseValue(text)
+				if err != nil {
+					return nil, fmt.Errorf("line %v %v %v: %v", first+r, field, columns[c], err)
+				}
+				dataset.Rows[r][c] = value
+				continue
+			}
+
+			word := strings.TrimSpace(text)
+			if missingValue(word) {
+				dataset.Rows[r][c] = math.NaN()
+				continue
+			}
+
+			// unknown words are added to the vocabulary of the dataset
+			if _, ok := indexes[c][word]; !ok {
+				indexes[c][word] = len(dataset.Categories[columns[c]])
+				dataset.Categories[columns[c]] = append(dataset.Categories[columns[c]], word)
+			}
+			dataset.Rows[r][c] = float64(indexes[c][word])
+		}
+	}
+
+	return dataset, nil
+}
+
+// LoadCSV reads a dataset from a csv file
+func LoadCSV(filename string) (*Dataset, error) {
+	return loadFile(filename, func(r io.Reader) (*Dataset, error) { return ReadCSV(r, ',') })
+}
+
+// LoadTSV reads a dataset from a tsv file
+func LoadTSV(filename string) (*Dataset, error) {
+	return loadFile(filename, func(r io.Reader) (*Dataset, error) { return ReadCSV(r, '\t') })
+}
+
+// LoadJSONL reads a dataset from a json lines file
+func LoadJSONL(filename string) (*Dataset, error) {
+	return loadFile(filename, ReadJSONL)
+}
+
+// Select chooses the input and target columns (empty inputs means every column that is not a target)
+func (dataset *Dataset) Select(inputs []string, targets []string) error {
+	for _, name := range append(append([]string{}, inputs...), targets...) {
+		if dataset.column(name) == -1 {
+			return fmt.Errorf("column %v not found", name)
+		}
+	}
+
+	dataset.Inputs = inputs
+	dataset.Targets = targets
+	return nil
+}
+
+// Samples converts the dataset to the inputs/outputs format used by Learns, LearnsRaw, etc
+func (dataset *Dataset) Samples() [][][]float64 {
+	inputs, targets := dataset.indexes(dataset.inputColumns()), dataset.indexes(dataset.Targets)
+	samples := make([][][]float64, len(dataset.Rows))
+
+	for r, row := range dataset.Rows {
+		sample := [][]float64{make([]float64, len(inputs)), make([]float64, len(targets))}
+		for i, c := range inputs {
+			sample[0][i] = row[c]
+		}
+		for i, c := range targets {
+			sample[1][i] = row[c]
+		}
+		samples[r] = sample
+	}
+
+	return samples
+}
+
+// Len is the amount of rows
+func (dataset *Dataset) Len() int {
+	return len(dataset.Rows)
+}
+
+// Column returns a copy of the values of a column
+func (dataset *Dataset) Column(name string) []float64 {
+	c := dataset.column(name)
+	if c == -1 {
+		return nil
+	}
+
+	values := make([]float64, len(dataset.Rows))
+	for r, row := range dataset.Rows {
+		values[r] = row[c]
+	}
+	return values
+}
+
+// DropMissing returns a dataset without the rows that have missing values in the selected columns
+func (dataset *Dataset) DropMissing() *Dataset {
+	columns := dataset.indexes(append(dataset.inputColumns(), dataset.Targets...))
+	rows := [][]float64{}
+
+	for _, row := range dataset.Rows {
+		missing := false
+		for _, c := range columns {
+			if math.IsNaN(row[c]) {
+				missing = true
+				break
+			}
+		}
+		if !missing {
+			rows = append(rows, row)
+		}
+	}
+
+	return dataset.subset(rows)
+}
+
+// FillMissing replaces missing values by a constant
+func (dataset *Dataset) FillMissing(value float64) {
+	for _, row := range dataset.Rows {
+		for c := range row {
+			if math.IsNaN(row[c]) {
+				row[c] = value
+			}
+		}
+	}
+}
+
+// FillMean replaces missing values by the mean of their column (categorical columns are skipped)
+func (dataset *Dataset) FillMean() {
+	for c, column := range dataset.Columns {
+		if _, ok := dataset.Categories[column]; ok {
+			continue
+		}
+
+		sum, count := 0.0, 0
+		for _, row := range dataset.Rows {
+			if !math.IsNaN(row[c]) {
+				sum += row[c]
+				count++
+			}
+		}
+		if count == 0 {
+			continue
+		}
+
+		for _, row := range dataset.Rows {
+			if math.IsNaN(row[c]) {
+				row[c] = sum / float64(count)
+			}
+		}
+	}
+}
+
+// Shuffle the rows in place (random can be nil to use crypto/rand)
+func (dataset *Dataset) Shuffle(random *Random) {
+	for i := len(dataset.Rows) - 1; i > 0; i-- {
+		j := random.Intn(i + 1)
+		dataset.Rows[i], dataset.Rows[j] = dataset.Rows[j], dataset.Rows[i]
+	}
+}
+
+// Split in two datasets, the first one has a ratio of the rows (like 0.8) and the second one the rest
+func (dataset *Dataset) Split(ratio float64) (*Dataset, *Dataset) {
+	if ratio < 0.0 || ratio > 1.0 || math.IsNaN(ratio) {
+		panic("need a split ratio between 0 and 1")
+	}
+
+	cut := int(math.Round(ratio * float64(len(dataset.Rows))))
+	return dataset.subset(dataset.Rows[:cut]), dataset.subset(dataset.Rows[cut:])
+}
+
+// Batches splits the rows in datasets of size rows (the last one can be smaller)
+func (dataset *Dataset) Batches(size int) []*Dataset {
+	if size <= 0 {
+		panic("need a positive batch size")
+	}
+
+	batches := []*Dataset{}
+	for from := 0; from < len(dataset.Rows); from += size {
+		to := from + size
+		if to > len(dataset.Rows) {
+			to = len(dataset.Rows)
+		}
+		batches = append(batches, dataset.subset(dataset.Rows[from:to]))
+	}
+	return batches
+}
+
+// KFold splits the rows in k parts, every fold tests on one part and trains on the others
+func (dataset *Dataset) KFold(k int) []Fold {
+	if k <= 0 {
+		panic("need a positive amount of folds")
+	}
+
+	folds := make([]Fold, k)
+	total := len(dataset.Rows)
+
+	for f := 0; f < k; f++ {
+		from, to := f*total/k, (f+1)*total/k
+
+		train := make([][]float64, 0, total-(to-from))
+		train = append(train, dataset.Rows[:from]...)
+		train = append(train, dataset.Rows[to:]...)
+
+		folds[f] = Fold{Train: dataset.subset(train), Test: dataset.subset(dataset.Rows[from:to])}
+	}
+
+	return folds
+}
+
+// LearnsDataset learns the selected columns of a dataset backed by Learns
+func (neural *Neural) LearnsDataset(dataset *Dataset) float64 {
+	return neural.Learns(dataset.Samples())
+}
+
+func (dataset *Dataset) subset(rows [][]float64) *Dataset {
+	return &Dataset{
+		Columns:    dataset.Columns,
+		Rows:       rows,
+		Categories: dataset.Categories,
+		Inputs:     dataset.Inputs,
+		Targets:    dataset.Targets,
+	}
+}
+
+func (dataset *Dataset) column(name string) int {
+	for c, column := range dataset.Columns {
+		if column == name {
+			return c
+		}
+	}
+	return -1
+}
+
+func (dataset *Dataset) indexes(names []string) []int {
+	indexes := make([]int, len(names))
+	for i, name := range names {
+		indexes[i] = dataset.column(name)
+	}
+	return indexes
+}
+
+func (dataset *Dataset) inputColumns() []string {
+	if len(dataset.Inputs) > 0 {
+		return dataset.Inputs
+	}
+
+	inputs := []string{}
+	for _, column := range dataset.Columns {
+		target := false
+		for _, name := range dataset.Targets {
+			if name == column {
+				target = true
+			}
+		}
+		if !target {
+			inputs = append(inputs, column)
+		}
+	}
+	return inputs
+}
+
+func loadFile(filename string, read func(r io.Reader) (*Dataset, error)) (*Dataset, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return read(file)
+}
+
+// parseValue converts a field to float, empty, NA, NaN, null and ? are missing values
+func parseValue(field string) (float64, error) {
+	field = strings.TrimSpace(field)
+
+	if missingValue(field) {
+		return math.NaN(), nil
+	}
+
+	return strconv.ParseFloat(field, 64)
+}
+
+func missingValue(field string) bool {
+	switch strings.ToLower(field) {
+	case "", "na", "nan", "null", "?":
+		return true
+	}
+	return false
+}
+
+// jsonField converts a json value to a text field (booleans are 1 or 0)
+func jsonField(raw interface{}) (string, error) {
+	switch value := raw.(type) {
+	case nil:
+		return "", nil
+	case float64:
+		return strconv.FormatFloat(value, 'g', -1, 64), nil
+	case bool:
+		if value {
+			return "1", nil
+		}
+		return "0", nil
+	case string:
+		return value, nil
+	}
+	return "", fmt.Errorf("unsupported value %v", raw)
+}
+
+// objectKeys returns the keys of a json object in order
+func objectKeys(line []byte) ([]string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(line))
+
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return nil, fmt.Errorf("need a json object")
+	}
+
+	keys := []string{}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, token.(string))
+
+		var skip json.RawMessage
+		if err := decoder.Decode(&skip); err != nil {
+			return nil, err
+		}
+	}
+
+	return keys, nil
+}
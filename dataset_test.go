@@ -0,0 +1,149 @@
+package neural
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadCSV(t *testing.T) {
+	dataset, err := ReadCSV(strings.NewReader("a,b,y\n1,2,0\n3,NA,1\n"), ',')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(dataset.Columns, []string{"a", "b", "y"}) || dataset.Len() != 2 || !math.IsNaN(dataset.Rows[1][1]) {
+		t.Fatalf("columns %v rows %v", dataset.Columns, dataset.Rows)
+	}
+
+	tsv, err := ReadCSV(strings.NewReader("a\tb\n1\t2\n"), '\t')
+	if err != nil || !reflect.DeepEqual(tsv.Rows, [][]float64{{1, 2}}) {
+		t.Fatalf("tsv rows %v (%v)", tsv.Rows, err)
+	}
+
+	if _, err := ReadCSV(strings.NewReader("a,b\n1,2\n3,x\n"), ','); err == nil || !strings.Contains(err.Error(), "line 3 column b") {
+		t.Fatalf("expected an error of line 3 column b, got %v", err)
+	}
+}
+
+func TestReadJSONL(t *testing.T) {
+	dataset, err := ReadJSONL(strings.NewReader("{\"a\": 1, \"b\": true}\n\n{\"c\": \"2.5\", \"a\": null}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// columns follow the order of appearance and absent keys are missing
+	if !reflect.DeepEqual(dataset.Columns, []string{"a", "b", "c"}) || dataset.Len() != 2 {
+		t.Fatalf("columns %v rows %v", dataset.Columns, dataset.Rows)
+	}
+	if row := dataset.Rows[0]; row[0] != 1 || row[1] != 1 || !math.IsNaN(row[2]) {
+		t.Fatalf("first row %v", row)
+	}
+	if row := dataset.Rows[1]; !math.IsNaN(row[0]) || !math.IsNaN(row[1]) || row[2] != 2.5 {
+		t.Fatalf("second row %v", row)
+	}
+
+	if _, err := ReadJSONL(strings.NewReader("{\"a\": [1]}\n")); err == nil {
+		t.Fatal("expected an error of an unsupported value")
+	}
+}
+
+func TestDatasetSelect(t *testing.T) {
+	dataset, _ := ReadCSV(strings.NewReader("a,b,c,y\n1,2,3,4\n"), ',')
+
+	if err := dataset.Select(nil, []string{"y"}); err != nil {
+		t.Fatal(err)
+	}
+	if samples := dataset.Samples(); !reflect.DeepEqual(samples, [][][]float64{{{1, 2, 3}, {4}}}) {
+		t.Fatalf("samples %v", samples)
+	}
+
+	if err := dataset.Select([]string{"c", "a"}, []string{"y", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if samples := dataset.Samples(); !reflect.DeepEqual(samples, [][][]float64{{{3, 1}, {4, 2}}}) {
+		t.Fatalf("samples %v", samples)
+	}
+
+	if err := dataset.Select(nil, []string{"z"}); err == nil {
+		t.Fatal("expected an error of an unknown column")
+	}
+}
+
+func TestDatasetMissing(t *testing.T) {
+	read := func() *Dataset {
+		dataset, _ := ReadCSV(strings.NewReader("a,b,y\n1,,1\n,4,0\n5,6,1\n"), ',')
+		dataset.Select([]string{"b"}, []string{"y"})
+		return dataset
+	}
+
+	// only the selected columns count
+	if dropped := read().DropMissing(); dropped.Len() != 2 || dropped.Rows[1][1] != 6 {
+		t.Fatalf("rows without missing values %v", dropped.Rows)
+	}
+
+	filled := read()
+	filled.FillMissing(-1)
+	if filled.Rows[0][1] != -1 || filled.Rows[1][0] != -1 {
+		t.Fatalf("filled rows %v", filled.Rows)
+	}
+
+	mean := read()
+	mean.FillMean()
+	if mean.Rows[0][1] != 5 || mean.Rows[1][0] != 3 {
+		t.Fatalf("filled rows %v", mean.Rows)
+	}
+}
+
+func TestDatasetSplits(t *testing.T) {
+	samples := make([][][]float64, 10)
+	for s := range samples {
+		samples[s] = [][]float64{{float64(s)}, {float64(s % 2)}}
+	}
+	dataset := NewDataset(samples)
+
+	if train, test := dataset.Split(0.8); train.Len() != 8 || test.Len() != 2 {
+		t.Fatalf("split of %v and %v rows", train.Len(), test.Len())
+	}
+	if train, test := dataset.Split(0); train.Len() != 0 || test.Len() != 10 {
+		t.Fatalf("split of %v and %v rows", train.Len(), test.Len())
+	}
+
+	batches := dataset.Batches(4)
+	if len(batches) != 3 || batches[2].Len() != 2 {
+		t.Fatalf("%v batches", len(batches))
+	}
+
+	for f, fold := range dataset.KFold(3) {
+		if fold.Train.Len()+fold.Test.Len() != 10 || fold.Test.Len() < 3 {
+			t.Fatalf("fold %v has %v and %v rows", f, fold.Train.Len(), fold.Test.Len())
+		}
+	}
+}
+
+func TestDatasetSplitsPanics(t *testing.T) {
+	dataset := NewDataset([][][]float64{{{0}, {0}}, {{1}, {1}}})
+
+	tests := []struct {
+		name string
+		fn   func()
+	}{
+		{"split ratio under 0", func() { dataset.Split(-0.1) }},
+		{"split ratio over 1", func() { dataset.Split(1.5) }},
+		{"batches of 0 rows", func() { dataset.Batches(0) }},
+		{"batches of negative rows", func() { dataset.Batches(-1) }},
+		{"0 folds", func() { dataset.KFold(0) }},
+		{"negative folds", func() { dataset.KFold(-2) }},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("expected a panic")
+				}
+			}()
+			test.fn()
+		})
+	}
+}
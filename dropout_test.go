@@ -0,0 +1,132 @@
+package neural
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+// dropped applies the dropout of a hidden layer to values in a mode
+func dropped(layer *Layer, values []float64, training bool) []float64 {
+	outs := append([]float64{}, values...)
+	layer.dropout(outs, training)
+	return outs
+}
+
+func TestDropoutMask(t *testing.T) {
+	neural := NewNeural([]*Layer{{Inputs: 1, Units: 1, Dropout: 0.3}, {Units: 1}})
+	neural.Seed(1)
+	layer := neural.Layers[0]
+
+	values := make([]float64, 20000)
+	for i := range values {
+		values[i] = 2
+	}
+
+	// training drops about the rate and keeps the rest as they are
+	zeros := 0
+	for _, value := range dropped(layer, values, true) {
+		if value == 0 {
+			zeros++
+		} else if value != 2 {
+			t.Fatalf("kept output changed to %v", value)
+		}
+	}
+	if rate := float64(zeros) / float64(len(values)); math.Abs(rate-0.3) > 0.01 {
+		t.Fatalf("dropped %v of the outputs", rate)
+	}
+
+	// inference scales by the keep probability, the expected output of training
+	for _, value := range dropped(layer, values[:10], false) {
+		if math.Abs(value-2*0.7) > 1e-12 {
+			t.Fatalf("inference output %v", value)
+		}
+	}
+}
+
+func TestAlphaDropoutKeepsMeanAndVariance(t *testing.T) {
+	neural := NewNeural([]*Layer{{Inputs: 1, Units: 1, Activation: "selu", Dropout: 0.2, AlphaDropout: true}, {Units: 1}})
+	neural.Seed(1)
+	layer := neural.Layers[0]
+
+	// standard normal values (Box-Muller), the distribution selu keeps
+	values := make([]float64, 100000)
+	for i := range values {
+		u, v := 1.0-neural.Random.Float64(), neural.Random.Float64()
+		values[i] = math.Sqrt(-2*math.Log(u)) * math.Cos(2*math.Pi*v)
+	}
+
+	mean, variance := meanVariance(dropped(layer, values, true))
+	if math.Abs(mean) > 0.02 || math.Abs(variance-1) > 0.02 {
+		t.Fatalf("mean %v and variance %v after alpha-dropout", mean, variance)
+	}
+
+	// inference doesn't change the outputs
+	if outs := dropped(layer, values[:10], false); !reflect.DeepEqual(outs, values[:10]) {
+		t.Fatalf("inference changed the outputs to %v", outs)
+	}
+}
+
+func TestDropoutModes(t *testing.T) {
+	neural := NewNeural([]*Layer{{Inputs: 2, Units: 50, Dropout: 0.5}, {Units: 1}})
+	neural.Seed(1)
+	neural.Reset()
+	inputs := []float64{0.5, -0.5}
+
+	if !neural.Training() {
+		t.Fatal("the default mode is not training")
+	}
+	if reflect.DeepEqual(neural.Forward(inputs), neural.ThinkRaw(inputs)) {
+		t.Fatal("Forward didn't use dropout in training mode")
+	}
+
+	neural.Eval()
+	if !reflect.DeepEqual(neural.Forward(inputs), neural.ThinkRaw(inputs)) || neural.Clone().Training() {
+		t.Fatal("Forward or the clone didn't use inference mode")
+	}
+
+	// LearnRaw always trains with random masks
+	neural.LearnRaw(inputs, []float64{1})
+	if zeros := countZeros(neural.Layers[0].mask); zeros == 0 {
+		t.Fatal("LearnRaw didn't drop outputs in inference mode")
+	}
+
+	neural.Train()
+	if !neural.Training() {
+		t.Fatal("Train didn't set training mode")
+	}
+}
+
+func TestDropoutExportImport(t *testing.T) {
+	neural := NewNeural([]*Layer{{Inputs: 2, Units: 3, Dropout: 0.25, AlphaDropout: true, Activation: "selu"}, {Units: 2, Dropout: 0.5}, {Units: 1}})
+	neural.Seed(1)
+	neural.Reset()
+
+	encoded, err := neural.Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := NewNeural([]*Layer{})
+	if err := imported.Import(encoded); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, layer := range neural.Layers {
+		if imported.Layers[i].Dropout != layer.Dropout || imported.Layers[i].AlphaDropout != layer.AlphaDropout {
+			t.Fatalf("layer %v imported dropout %v alpha %v", i, imported.Layers[i].Dropout, imported.Layers[i].AlphaDropout)
+		}
+	}
+	if a, b := neural.ThinkRaw([]float64{0.3, 0.6}), imported.ThinkRaw([]float64{0.3, 0.6}); !reflect.DeepEqual(a, b) {
+		t.Fatalf("outputs changed from %v to %v", a, b)
+	}
+}
+
+func countZeros(values []float64) int {
+	zeros := 0
+	for _, value := range values {
+		if value == 0 {
+			zeros++
+		}
+	}
+	return zeros
+}
@@ -0,0 +1,193 @@
+package neural
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Embedding layers map every input (an index of the vocabulary) to a trainable vector of Dimensions values,
+// the outputs are the vectors of the Sequence of inputs one after the other. A negative index is padding (zeros)
+// and so is an index outside of the vocabulary (grow it to learn new indices).
+// Every index has a neuron whose weights are the vector (the bias is not used nor a param), only the vectors
+// of the indices seen since the last ZeroGrad are updated (sparse), so their momentum and decay are lazy
+
+// embeddingKind keeps the indices of the last forward and the ones with gradients since the last ZeroGrad
+type embeddingKind struct {
+	NeuronKind
+	indices []int
+	touched map[int]bool
+}
+
+func (layer *Layer) isEmbedding() bool {
+	return layer.Type == "embedding"
+}
+
+// New checks the definition and creates a vector per index (default activation is linear)
+func (*embeddingKind) New(layer *Layer) {
+	if layer.Sequence == 0 {
+		layer.Sequence = layer.Inputs
+	}
+	if layer.Inputs == 0 {
+		layer.Inputs = layer.Sequence
+	}
+	if layer.Inputs != layer.Sequence {
+		panic("need inputs of the sequence length in embedding layers")
+	}
+	if layer.Vocabulary < 1 || layer.Dimensions < 1 {
+		panic("need vocabulary and dimensions in embedding layers")
+	}
+
+	layer.Activation = "linear"
+	layer.Units = layer.Sequence * layer.Dimensions
+	layer.Neurons = make([]*Neuron, 0, layer.Vocabulary)
+	layer.grow(layer.Vocabulary)
+}
+
+// Params are only the vectors (the bias is not used)
+func (*embeddingKind) Params(layer *Layer, units []int) []Param {
+	return layer.neuronParams(units, false)
+}
+
+func (kind *embeddingKind) State(layer *Layer) interface{} {
+	return kind.indices
+}
+
+func (kind *embeddingKind) SetState(layer *Layer, state interface{}) {
+	kind.indices = state.([]int)
+}
+
+func (kind *embeddingKind) Import(layer *Layer, data json.RawMessage) error {
+	if len(layer.Neurons) == 0 {
+		return fmt.Errorf("need the vectors of embedding layers")
+	}
+	kind.NeuronKind.Import(layer, data)
+	layer.Vocabulary = len(layer.Neurons)
+	layer.Dimensions = len(layer.Neurons[0].Weights)
+	layer.Inputs = layer.Sequence
+	layer.Units = layer.Sequence * layer.Dimensions
+	return nil
+}
+
+func (kind *embeddingKind) Reset(layer *Layer) {
+	kind.NeuronKind.Reset(layer)
+	for _, neuron := range layer.Neurons {
+		neuron.Bias = 0.0
+	}
+	kind.touched = nil
+}
+
+// grow adds random vectors up to the size of the vocabulary
+func (layer *Layer) grow(vocabulary int) {
+	for len(layer.Neurons) < vocabulary {
+		neuron := NewNeuron(layer, layer.Dimensions)
+		neuron.Bias = 0.0
+		layer.Neurons = append(layer.Neurons, neuron)
+	}
+	layer.Vocabulary = vocabulary
+}
+
+// Grow adds random vectors for new indices, the vectors of the current indices stay the same
+func (layer *Layer) Grow(vocabulary int) {
+	if !layer.isEmbedding() {
+		panic("need an embedding layer to grow")
+	}
+	if vocabulary < layer.Vocabulary {
+		panic("need a bigger vocabulary to grow")
+	}
+	layer.grow(vocabulary)
+}
+
+// SetVectors replaces the vectors from the first index (like pretrained vectors), the vocabulary grows if needed
+// Use Frozen to keep them while learning
+func (layer *Layer) SetVectors(vectors [][]float64) error {
+	if !layer.isEmbedding() {
+		return fmt.Errorf("layer type %v has no vectors", layer.Type)
+	}
+	for index, vector := range vectors {
+		if len(vector) != layer.Dimensions {
+			return fmt.Errorf("vector %v has %v dimensions but layer has %v", index, len(vector), layer.Dimensions)
+		}
+	}
+
+	if len(vectors) > layer.Vocabulary {
+		layer.grow(len(vectors))
+	}
+	for index, vector := range vectors {
+		copy(layer.Neurons[index].Weights, vector)
+	}
+	return nil
+}
+
+// Vectors returns a copy of the vector of every index
+func (layer *Layer) Vectors() [][]float64 {
+	vectors := make([][]float64, len(layer.Neurons))
+	for index, neuron := range layer.Neurons {
+		vectors[index] = append([]float64(nil), neuron.Weights...)
+	}
+	return vectors
+}
+
+// index of the vocabulary given by an input, -1 for padding (also out of the vocabulary)
+func (layer *Layer) index(input float64) int {
+	index := int(math.Round(input))
+	if index < 0 || index >= layer.Vocabulary || math.IsNaN(input) {
+		return -1
+	}
+	return index
+}
+
+// Forward writes the vector of every input
+func (kind *embeddingKind) Forward(layer *Layer, inputs []float64, outs []float64, training bool) {
+	kind.indices = make([]int, layer.Sequence)
+
+	for t := range kind.indices {
+		kind.indices[t] = layer.index(inputs[t])
+		if kind.indices[t] >= 0 {
+			copy(outs[t*layer.Dimensions:], layer.Neurons[kind.indices[t]].Weights)
+		}
+	}
+}
+
+// Backward adds the gradients of the vectors of the last inputs, indices don't have errors
+func (kind *embeddingKind) Backward(layer *Layer, errors []float64) []float64 {
+	for t, index := range kind.indices {
+		if index < 0 {
+			continue
+		}
+
+		neuron := layer.Neurons[index]
+		for d := 0; d < layer.Dimensions; d++ {
+			neuron.Gradients[d] -= errors[t*layer.Dimensions+d]
+		}
+		kind.touch(index)
+	}
+
+	return make([]float64, layer.Inputs)
+}
+
+// touch keeps an index with gradients
+func (kind *embeddingKind) touch(index int) {
+	if kind.touched == nil {
+		kind.touched = map[int]bool{}
+	}
+	kind.touched[index] = true
+}
+
+// Rows are the indices with gradients since the last ZeroGrad in order
+func (kind *embeddingKind) Rows(layer *Layer) []int {
+	rows := make([]int, 0, len(kind.touched))
+	for index := range kind.touched {
+		rows = append(rows, index)
+	}
+	sort.Ints(rows)
+	return rows
+}
+
+func (kind *embeddingKind) SetRows(layer *Layer, rows []int) {
+	kind.touched = nil
+	for _, index := range rows {
+		kind.touch(index)
+	}
+}
@@ -0,0 +1,94 @@
+package neural
+
+import (
+	"reflect"
+	"testing"
+)
+
+// embeddingNeural has a vocabulary of 6 vectors of 3 dimensions for sequences of 4 indices
+func embeddingNeural() *Neural {
+	neural := NewNeural([]*Layer{
+		{Type: "embedding", Inputs: 4, Vocabulary: 6, Dimensions: 3, WeightDecay: 0.01},
+		{Units: 5, Activation: "tanh"},
+		{Units: 2},
+	})
+	neural.Seed(1)
+	neural.Reset()
+	return neural
+}
+
+func TestGradCheckEmbedding(t *testing.T) {
+	// repeated indices add their gradients and padding has none
+	checkGradients(t, embeddingNeural(), []float64{2, 0, -1, 2}, []float64{0.3, 0.8})
+}
+
+func TestEmbeddingSparseUpdates(t *testing.T) {
+	tests := []struct {
+		name    string
+		batch   int
+		dataset [][][]float64
+		touched []int
+	}{
+		{"sample", 1, [][][]float64{{{2, 0, -1, 2}, {1, 0}}}, []int{0, 2}},
+		{"batch", 2, [][][]float64{{{2, 0, -1, 2}, {1, 0}}, {{-1, 4, 4, 0}, {0, 1}}}, []int{0, 2, 4}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			neural := embeddingNeural()
+			neural.Batch, neural.Workers = test.batch, 2
+
+			// momentum of the first learn that would move every vector again if the updates weren't sparse
+			neural.LearnsRaw([][][]float64{{{1, 3, 5, -1}, {0, 0}}})
+			before := neural.Layers[0].Vectors()
+			neural.LearnsRaw(test.dataset)
+			after := neural.Layers[0].Vectors()
+
+			for index := range before {
+				changed := !reflect.DeepEqual(before[index], after[index])
+				touched := false
+				for _, t := range test.touched {
+					touched = touched || t == index
+				}
+				if changed != touched {
+					t.Fatalf("vector %v changed %v but it was touched %v", index, changed, touched)
+				}
+			}
+		})
+	}
+}
+
+func TestEmbeddingRoundTrip(t *testing.T) {
+	neural := embeddingNeural()
+	neural.Layers[0].Grow(8)
+	neural.LearnRaw([]float64{7, 0, -1, 2}, []float64{1, 0})
+	imported := roundTrip(t, neural)
+
+	if imported.Layers[0].Vocabulary != 8 || !reflect.DeepEqual(imported.Layers[0].Vectors(), neural.Layers[0].Vectors()) {
+		t.Fatal("vectors changed")
+	}
+	if !reflect.DeepEqual(imported.Params(), neural.Params()) {
+		t.Fatal("params changed")
+	}
+	inputs := []float64{7, 3, 3, -1}
+	if !reflect.DeepEqual(imported.ThinkRaw(inputs), neural.ThinkRaw(inputs)) {
+		t.Fatal("thinks different after import")
+	}
+}
+
+func TestEmbeddingOutOfVocabulary(t *testing.T) {
+	neural := embeddingNeural()
+
+	// indices outside of the vocabulary are padding, in inference and learning
+	if !reflect.DeepEqual(neural.ThinkRaw([]float64{2, 6, 40, 1}), neural.ThinkRaw([]float64{2, -1, -1, 1})) {
+		t.Fatal("an index out of the vocabulary is not padding")
+	}
+
+	before := neural.Layers[0].Vectors()
+	neural.LearnRaw([]float64{6, 9, -1, 6}, []float64{1, 0})
+	if err := neural.Err(); err != nil || !reflect.DeepEqual(neural.Layers[0].Vectors(), before) {
+		t.Fatalf("vectors changed learning indices out of the vocabulary (%v)", err)
+	}
+
+	checkGradients(t, embeddingNeural(), []float64{2, 7, 0, 2}, []float64{0.3, 0.8})
+}
@@ -0,0 +1,250 @@
+package neural
+
+import (
+	"math"
+)
+
+// GradCheck compares the gradients of backpropagation with central finite differences for every weight and bias
+// Inputs and outputs are raw, the neural is used in its mode (see Train and Eval) and every forward of the check
+// repeats the same dropout masks (training mode needs a seeded neural for dropout)
+// It returns the maximum relative error of every layer (under 1e-5 is correct, wrong gradients are usually near 1) and keeps the neural as it was
+// The error is |analytic - numeric| / max(|analytic|, |numeric|, 1e-8), so gradients that are zero need a small loss (targets near
+// the outputs) to keep the rounding of the finite differences under that
+func GradCheck(neural *Neural, inputs []float64, outputs []float64) []float64 {
+	training := neural.Training()
+	params := neural.Params()
+	gradients := neural.Grads()
+	states := neural.states()
+	saved := neural.forwardState(training)
+	defer func() {
+		neural.SetParams(params)
+		neural.setGrads(gradients)
+		neural.setStates(states)
+		neural.setForwardState(saved)
+	}()
+
+	// loss is half of the squared error, the gradients of backward are its derivatives
+	// every forward starts from the same state of recurrent layers, random sources and running statistics
+	loss := func() float64 {
+		neural.setStates(states)
+		neural.setForwardState(saved)
+		return lossObjective("mse", neural.forward(inputs, training), outputs)
+	}
+
+	neural.ZeroGrad()
+	neural.setStates(states)
+	neural.setForwardState(saved)
+	neural.backward(neural.forward(inputs, training), outputs)
+
+	return neural.gradCheck(params, loss)
+}
+
+// GradCheckSequence is GradCheck for a sequence (outputs per step or one for the last step) starting from the current state
+// It checks the backpropagation through time of the whole sequence like a single chunk of LearnSequenceRaw
+func GradCheckSequence(neural *Neural, inputs [][]float64, outputs [][]float64) []float64 {
+	if len(outputs) != len(inputs) && len(outputs) != 1 {
+		panic("need an output per step or only one for the last step")
+	}
+
+	training := neural.Training()
+	params := neural.Params()
+	gradients := neural.Grads()
+	states := neural.states()
+	saved := neural.forwardState(training)
+	defer func() {
+		neural.SetParams(params)
+		neural.setGrads(gradients)
+		neural.setStates(states)
+		neural.setForwardState(saved)
+	}()
+
+	targets := make([][]float64, len(inputs))
+	if len(outputs) == len(inputs) {
+		copy(targets, outputs)
+	} else {
+		targets[len(inputs)-1] = outputs[0]
+	}
+
+	// loss is the average of the steps with outputs like the gradients of the chunk
+	loss := func() float64 {
+		neural.setStates(states)
+		neural.setForwardState(saved)
+		sum := 0.0
+		for t, step := range inputs {
+			current := neural.forward(step, training)
+			if targets[t] != nil {
+				sum += lossObjective("mse", current, targets[t])
+			}
+		}
+		return sum / float64(len(outputs))
+	}
+
+	neural.setStates(states)
+	neural.setForwardState(saved)
+	neural.backwardChunk(inputs, targets, training)
+	neural.clearCarries()
+
+	return neural.gradCheck(params, loss)
+}
+
+// GradCheckBatch is GradCheck for a mini-batch of raw samples in training mode, like LearnsRaw with Batch:
+// batchnorm uses the statistics of the batch and every sample repeats its dropout masks (it needs a seeded neural)
+// The loss is the average of the samples, the running statistics are kept
+func GradCheckBatch(neural *Neural, batch [][][]float64) []float64 {
+	if neural.Random == nil {
+		panic("need a seeded neural to check the gradients of a batch")
+	}
+
+	params := neural.Params()
+	gradients := neural.Grads()
+	saved := neural.forwardState(true)
+	random := neural.Random.State
+	restore := func() {
+		neural.setForwardState(saved)
+		neural.Random.State = random
+	}
+	defer func() {
+		neural.SetParams(params)
+		neural.setGrads(gradients)
+		restore()
+	}()
+
+	// every pass learns the batch without the update, from the same random sources and running statistics
+	pass := func() (float64, []float64) {
+		restore()
+		neural.replicate(1)
+		slots := neural.learnBatch(batch, 1)
+
+		loss, grads := 0.0, make([]float64, len(slots[0].grads))
+		for s, slot := range slots {
+			loss += slot.loss * float64(len(batch[s][1])) / 2.0 / float64(len(slots))
+			for p, grad := range slot.grads {
+				grads[p] += grad / float64(len(slots))
+			}
+		}
+
+		for i := 0; i < neural.MaxLayers; i++ {
+			if layer := neural.Layers[i]; layer.batched() != nil {
+				samples := make([][]float64, len(slots))
+				for s, slot := range slots {
+					samples[s] = slot.samples[i]
+				}
+				layer.batched().EndBatch(layer, samples)
+			}
+		}
+		return loss, grads
+	}
+
+	_, grads := pass()
+	neural.setGrads(grads)
+
+	return neural.gradCheck(params, func() float64 {
+		loss, _ := pass()
+		return loss
+	})
+}
+
+// GradCheckGraph is GradCheck for a graph with raw inputs and outputs by name, the loss is the weighted sum of the heads
+// It returns the maximum relative error of the layer of every node with a layer (in order)
+func GradCheckGraph(graph *Graph, inputs map[string][]float64, outputs map[string][]float64) []float64 {
+	neural := graph.neural
+
+	training := neural.Training()
+	params := neural.Params()
+	gradients := neural.Grads()
+	saved := neural.forwardState(training)
+	defer func() {
+		neural.SetParams(params)
+		neural.setGrads(gradients)
+		neural.setForwardState(saved)
+	}()
+
+	loss := func() float64 {
+		neural.setForwardState(saved)
+		values := graph.forward(inputs, training)
+		sum := 0.0
+		for _, head := range graph.Heads {
+			if outputs[head.Node] != nil {
+				sum += head.Weight * lossObjective(head.Loss, values[head.Node], outputs[head.Node])
+			}
+		}
+		return sum
+	}
+
+	neural.ZeroGrad()
+	neural.setForwardState(saved)
+	graph.forward(inputs, training)
+	graph.Backward(outputs)
+
+	return neural.gradCheck(params, loss)
+}
+
+// gradCheck compares the gradients of the neural with central finite differences of the loss around the params
+func (neural *Neural) gradCheck(params []float64, loss func() float64) []float64 {
+	const epsilon = 1e-5
+
+	analytic := neural.Grads()
+	errors := make([]float64, neural.MaxLayers)
+	shifted := append([]float64{}, params...)
+
+	for i, p := 0, 0; i < neural.MaxLayers; i++ {
+		for end := p + neural.Layers[i].NumParams(); p < end; p++ {
+			shifted[p] = params[p] + epsilon
+			neural.SetParams(shifted)
+			plus := loss()
+
+			shifted[p] = params[p] - epsilon
+			neural.SetParams(shifted)
+			minus := loss()
+
+			shifted[p] = params[p]
+
+			numeric := (plus - minus) / (2.0 * epsilon)
+			scale := math.Max(math.Max(math.Abs(analytic[p]), math.Abs(numeric)), 1e-8)
+			errors[i] = math.Max(errors[i], math.Abs(analytic[p]-numeric)/scale)
+		}
+	}
+
+	return errors
+}
+
+// forwardState is what a forward changes besides its outputs: the random source of every layer and the running statistics
+type forwardState struct {
+	random   []uint64
+	mean     [][]float64
+	variance [][]float64
+}
+
+// forwardState of the neural, the masks of dropout can't be repeated in training mode without a seeded random source
+func (neural *Neural) forwardState(training bool) forwardState {
+	state := forwardState{
+		random:   make([]uint64, neural.MaxLayers),
+		mean:     make([][]float64, neural.MaxLayers),
+		variance: make([][]float64, neural.MaxLayers),
+	}
+
+	for i := 0; i < neural.MaxLayers; i++ {
+		layer := neural.Layers[i]
+		if layer.Random != nil {
+			state.random[i] = layer.Random.State
+		} else if training && layer.Dropout > 0.0 {
+			panic("need a seeded neural to check the gradients of dropout in training mode")
+		}
+		state.mean[i] = append([]float64(nil), layer.Mean...)
+		state.variance[i] = append([]float64(nil), layer.Variance...)
+	}
+
+	return state
+}
+
+// setForwardState restores the random sources and running statistics
+func (neural *Neural) setForwardState(state forwardState) {
+	for i := 0; i < neural.MaxLayers; i++ {
+		layer := neural.Layers[i]
+		if layer.Random != nil {
+			layer.Random.State = state.random[i]
+		}
+		copy(layer.Mean, state.mean[i])
+		copy(layer.Variance, state.variance[i])
+	}
+}
@@ -0,0 +1,113 @@
+package neural
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// gradTolerance is the maximum relative error of correct gradients
+const gradTolerance = 1e-5
+
+// checkGradients fails if GradCheck finds a layer with wrong gradients
+func checkGradients(t *testing.T, neural *Neural, inputs []float64, outputs []float64) {
+	t.Helper()
+
+	errors := GradCheck(neural, inputs, outputs)
+	for i, err := range errors {
+		if err > gradTolerance {
+			t.Fatalf("layer %v (%v) has a relative error of %.2e, all layers %.2e", i, neural.Layers[i].Type, err, errors)
+		}
+	}
+}
+
+func TestGradCheckActivations(t *testing.T) {
+	inputs := []float64{0.3, -0.8, 0.5}
+	outputs := []float64{0.2, 0.9}
+
+	// every built-in activation, alone and mixed with normalization and dropout layers, in both modes
+	for _, activation := range []string{"linear", "sigmoid", "tanh", "relu", "selu"} {
+		for _, kind := range []string{"dense", "batchnorm", "layernorm", "dropout", "alphadropout"} {
+			for _, eval := range []bool{false, true} {
+				t.Run(fmt.Sprintf("%v/%v/eval=%v", activation, kind, eval), func(t *testing.T) {
+					neural := NewNeural(mixed(activation, kind))
+					neural.Seed(1)
+					neural.Reset()
+					if eval {
+						neural.Eval()
+					}
+
+					checkGradients(t, neural, inputs, outputs)
+				})
+			}
+		}
+	}
+}
+
+// mixed are layers of an activation with a kind of layer (dense, batchnorm, layernorm, dropout or alphadropout)
+func mixed(activation string, kind string) []*Layer {
+	hidden := &Layer{Units: 4, Activation: activation}
+	if kind == "dropout" || kind == "alphadropout" {
+		hidden.Dropout, hidden.AlphaDropout = 0.4, kind == "alphadropout"
+	}
+
+	layers := []*Layer{{Inputs: 3, Units: 5, Activation: activation}, hidden}
+	if kind == "batchnorm" || kind == "layernorm" {
+		layers = append(layers, &Layer{Type: kind})
+	}
+	return append(layers, &Layer{Units: 2, Activation: activation})
+}
+
+func TestGradCheckBatch(t *testing.T) {
+	batch := [][][]float64{
+		{{0.3, -0.8, 0.5}, {0.2, 0.9}},
+		{{0.1, 0.4, -0.9}, {0.7, 0.1}},
+		{{-0.6, 0.2, 0.7}, {0.5, 0.5}},
+		{{0.9, -0.1, 0.2}, {0.1, 0.3}},
+	}
+
+	// batch statistics of batchnorm and the masks of dropout of every sample
+	// (linear layers before batchnorm are left out, the mean of the batch removes their biases so they have no gradient)
+	for _, activation := range []string{"sigmoid", "tanh", "selu"} {
+		for _, kind := range []string{"dense", "batchnorm", "dropout", "alphadropout"} {
+			t.Run(fmt.Sprintf("%v/%v", activation, kind), func(t *testing.T) {
+				neural := NewNeural(mixed(activation, kind))
+				neural.Seed(1)
+				neural.Reset()
+				params := neural.Params()
+				mean, variance := append([]float64(nil), neural.Layers[2].Mean...), append([]float64(nil), neural.Layers[2].Variance...)
+
+				errors := GradCheckBatch(neural, batch)
+				for i, err := range errors {
+					if err > gradTolerance {
+						t.Fatalf("layer %v (%v) has a relative error of %.2e, all layers %.2e", i, neural.Layers[i].Type, err, errors)
+					}
+				}
+
+				if !reflect.DeepEqual(neural.Params(), params) || !reflect.DeepEqual(neural.Layers[2].Mean, mean) || !reflect.DeepEqual(neural.Layers[2].Variance, variance) {
+					t.Fatal("GradCheckBatch changed the params or running statistics")
+				}
+			})
+		}
+	}
+}
+
+func TestGradCheckKeepsNeural(t *testing.T) {
+	neural := NewNeural([]*Layer{{Inputs: 2, Units: 3}, {Units: 1}})
+	neural.Seed(1)
+	neural.Reset()
+
+	params := neural.Params()
+	neural.Forward([]float64{1, 0})
+	neural.Backward([]float64{1})
+	grads := neural.Grads()
+
+	GradCheck(neural, []float64{0, 1}, []float64{0})
+
+	after, afterGrads := neural.Params(), neural.Grads()
+	for p := range params {
+		if after[p] != params[p] || afterGrads[p] != grads[p] {
+			t.Fatal("GradCheck changed the params or gradients")
+		}
+	}
+}
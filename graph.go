@@ -0,0 +1,474 @@
+package neural
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math"
+	"strconv"
+)
+
+// Graph is a model of named nodes connected without cycles (functional style) that works with raw values
+// Every node takes named inputs or previous nodes, merged by concat (default), add or multiply,
+// and processes them with its layer (or passes the merge as it is). Heads are the nodes used as outputs,
+// each one with its own loss and weight, and backpropagation adds the errors of every node that takes a value
+type Graph struct {
+	Inputs []GraphInput `json:"Inputs"`
+	Nodes  []*Node      `json:"Nodes"`
+	Heads  []Head       `json:"Heads"`
+	// Source of randomness shared by all layers (default is crypto/rand)
+	Random *Random `json:"-"`
+	// Clip every gradient to [-ClipValue, ClipValue] (default is no clipping)
+	ClipValue float64 `json:"-"`
+	// Scale the gradients of all layers when their global norm is bigger (default is no clipping)
+	ClipNorm float64 `json:"-"`
+	// Layers of the nodes in order, they learn like the layers of a neural
+	neural *Neural
+	// Size of every input and node by name
+	sizes map[string]int
+	// Values of every input and node of the last Forward
+	values map[string][]float64
+}
+
+// GraphInput is a named input of the graph
+type GraphInput struct {
+	Name string `json:"Name"`
+	Size int    `json:"Size"`
+}
+
+// Node takes the values of inputs or previous nodes by name
+type Node struct {
+	Name string   `json:"Name"`
+	From []string `json:"From"`
+	// Default merge is concat, add and multiply need values of the same size
+	Merge string `json:"Merge,omitempty"`
+	// Layer processing the merged values (default is none), its inputs are the merged size
+	Layer *Layer `json:"Layer,omitempty"`
+}
+
+// Head is a node used as output
+type Head struct {
+	Node string `json:"Node"`
+	// Default loss is mse, crossentropy is for outputs in (0, 1) like sigmoid
+	Loss string `json:"Loss,omitempty"`
+	// Multiplier of the loss and errors of the head (default is 1)
+	Weight float64 `json:"Weight,omitempty"`
+}
+
+// NewGraph creates a graph, nodes are defined after the nodes they take
+func NewGraph(inputs []GraphInput, nodes []*Node, heads []Head) *Graph {
+	graph := &Graph{Inputs: inputs, Nodes: nodes, Heads: heads}
+	if err := graph.link(true); err != nil {
+		panic(err.Error())
+	}
+	return graph
+}
+
+// Residual is a block of layers whose output is added to its input (name = from + layers(from))
+// The last layer needs the units of the input, the nodes of the layers are named name/0, name/1, etc
+func Residual(name string, from string, layers ...*Layer) []*Node {
+	nodes := make([]*Node, 0, len(layers)+1)
+
+	previous := from
+	for i, layer := range layers {
+		node := &Node{Name: name + "/" + strconv.Itoa(i), From: []string{previous}, Layer: layer}
+		nodes = append(nodes, node)
+		previous = node.Name
+	}
+
+	return append(nodes, &Node{Name: name, From: []string{from, previous}, Merge: "add"})
+}
+
+// link checks the graph, creates the layers of the nodes (or checks the imported ones) and sets the sizes
+// Layers without a source of randomness use the one of the graph
+func (graph *Graph) link(create bool) error {
+	graph.sizes = map[string]int{}
+	layers := []*Layer{}
+
+	for _, input := range graph.Inputs {
+		if input.Name == "" || input.Size < 1 {
+			return errors.New("need a name and size for every graph input")
+		}
+		if _, ok := graph.sizes[input.Name]; ok {
+			return errors.New("need unique names in graphs")
+		}
+		graph.sizes[input.Name] = input.Size
+	}
+
+	for _, node := range graph.Nodes {
+		if node.Name == "" || len(node.From) == 0 {
+			return errors.New("need a name and sources for every node")
+		}
+		if _, ok := graph.sizes[node.Name]; ok {
+			return errors.New("need unique names in graphs")
+		}
+
+		size := 0
+		for i, from := range node.From {
+			fromSize, ok := graph.sizes[from]
+			if !ok {
+				return errors.New("need nodes defined after their sources")
+			}
+
+			switch node.Merge {
+			case "", "concat":
+				size += fromSize
+			case "add", "multiply":
+				if i > 0 && fromSize != size {
+					return errors.New("need sources of the same size to add or multiply")
+				}
+				size = fromSize
+			default:
+				return errors.New("need a valid merge")
+			}
+		}
+
+		if layer := node.Layer; layer != nil {
+			for _, previous := range layers {
+				if previous == layer {
+					return errors.New("need a layer per node")
+				}
+			}
+
+			if layer.Random == nil {
+				layer.Random = graph.Random
+			}
+			if create {
+				if layer.Inputs == 0 && layer.Channels > 0 {
+					layer.Inputs = layer.shapeInputs()
+				}
+				if layer.Inputs == 0 {
+					layer.Inputs = size
+				}
+				if layer.Units == 0 && layer.isNorm() {
+					layer.Units = layer.Inputs
+				}
+				NewLayer(layer)
+			}
+			if layer.Inputs != size {
+				return errors.New("need layers with inputs of the merged size")
+			}
+
+			layers = append(layers, layer)
+			size = layer.Units
+		}
+
+		graph.sizes[node.Name] = size
+	}
+
+	for h := range graph.Heads {
+		head := &graph.Heads[h]
+		if _, ok := graph.sizes[head.Node]; !ok {
+			return errors.New("need heads of existing nodes")
+		}
+		if head.Loss != "" && head.Loss != "mse" && head.Loss != "crossentropy" {
+			return errors.New("need a valid loss")
+		}
+		if head.Weight == 0.0 {
+			head.Weight = 1.0
+		}
+	}
+
+	graph.neural = &Neural{MaxLayers: len(layers), Layers: layers, Random: graph.Random}
+	return nil
+}
+
+// ThinkRaw process the graph forward based on raw inputs by name and returns the raw outputs of every head
+// It overwrites what Backward needs of the last Forward, so a Backward after it panics
+func (graph *Graph) ThinkRaw(inputs map[string][]float64) map[string][]float64 {
+	outputs := graph.forward(inputs, false)
+	graph.values = nil
+	return outputs
+}
+
+// forward process the nodes in order in training (random dropout) or inference mode
+func (graph *Graph) forward(inputs map[string][]float64, training bool) map[string][]float64 {
+	graph.values = map[string][]float64{}
+
+	for _, input := range graph.Inputs {
+		if len(inputs[input.Name]) != input.Size {
+			panic("need the values of every graph input")
+		}
+		graph.values[input.Name] = inputs[input.Name]
+	}
+
+	for _, node := range graph.Nodes {
+		values := node.merge(graph.values, graph.sizes[node.Name])
+		if node.Layer != nil {
+			values = node.Layer.forward(values, training)
+		}
+		graph.values[node.Name] = values
+	}
+
+	outputs := map[string][]float64{}
+	for _, head := range graph.Heads {
+		outputs[head.Node] = graph.values[head.Node]
+	}
+	return outputs
+}
+
+// merge the values of the sources of the node
+func (node *Node) merge(values map[string][]float64, size int) []float64 {
+	if node.Merge == "" || node.Merge == "concat" {
+		merged := make([]float64, 0, size)
+		for _, from := range node.From {
+			merged = append(merged, values[from]...)
+		}
+		return merged
+	}
+
+	merged := append([]float64(nil), values[node.From[0]]...)
+	for _, from := range node.From[1:] {
+		for i, value := range values[from] {
+			if node.Merge == "add" {
+				merged[i] += value
+			} else {
+				merged[i] *= value
+			}
+		}
+	}
+	return merged
+}
+
+// unmerge splits the errors of the merged values into the errors of every source
+func (node *Node) unmerge(errors []float64, values map[string][]float64) [][]float64 {
+	sources := make([][]float64, len(node.From))
+
+	for s, from := range node.From {
+		switch node.Merge {
+		case "add":
+			sources[s] = errors
+		case "multiply":
+			// the derivative of a product by a source is the product of the other sources
+			sources[s] = make([]float64, len(errors))
+			for i := range errors {
+				sources[s][i] = errors[i]
+				for o, other := range node.From {
+					if o != s {
+						sources[s][i] *= values[other][i]
+					}
+				}
+			}
+		default:
+			sources[s], errors = errors[:len(values[from])], errors[len(values[from]):]
+		}
+	}
+
+	return sources
+}
+
+// Forward thinks raw inputs in the mode of the graph (training unless Eval) keeping what the next Backward needs
+func (graph *Graph) Forward(inputs map[string][]float64) map[string][]float64 {
+	return graph.forward(inputs, graph.neural.Training())
+}
+
+// Train sets training mode (the default) for Forward and GradCheckGraph, see Neural.Train
+func (graph *Graph) Train() {
+	graph.neural.Train()
+}
+
+// Eval sets inference mode for Forward and GradCheckGraph, see Neural.Eval
+func (graph *Graph) Eval() {
+	graph.neural.Eval()
+}
+
+// Training is true in training mode
+func (graph *Graph) Training() bool {
+	return graph.neural.Training()
+}
+
+// Backward adds the gradients of the raw outputs expected by every head for the last Forward
+// It returns the loss, the sum of the loss of every head by its weight (heads without outputs are skipped)
+func (graph *Graph) Backward(outputs map[string][]float64) float64 {
+	if graph.values == nil {
+		panic("need a forward before backward (without thinking in between)")
+	}
+
+	loss := 0.0
+	errors := map[string][]float64{}
+
+	for _, head := range graph.Heads {
+		if outputs[head.Node] == nil {
+			continue
+		}
+
+		headLoss, headErrors := lossErrors(head.Loss, graph.values[head.Node], outputs[head.Node])
+		loss += head.Weight * headLoss
+		for o := range headErrors {
+			headErrors[o] *= head.Weight
+		}
+		graph.addErrors(errors, head.Node, headErrors)
+	}
+
+	for n := len(graph.Nodes) - 1; n >= 0; n-- {
+		node := graph.Nodes[n]
+		nodeErrors := errors[node.Name]
+		if nodeErrors == nil {
+			continue
+		}
+
+		if node.Layer != nil {
+			nodeErrors = node.Layer.backward(nodeErrors)
+		}
+		for s, sourceErrors := range node.unmerge(nodeErrors, graph.values) {
+			graph.addErrors(errors, node.From[s], sourceErrors)
+		}
+	}
+
+	return loss
+}
+
+// addErrors adds the errors of a value taken by several nodes or heads
+func (graph *Graph) addErrors(errors map[string][]float64, name string, values []float64) {
+	if errors[name] == nil {
+		errors[name] = make([]float64, len(values))
+	}
+	for i, value := range values {
+		errors[name][i] += value
+	}
+}
+
+// Step updates the weights of all layers with the accumulated gradients (clipped if enabled)
+// It returns the error that aborted the training (same as Err)
+func (graph *Graph) Step() error {
+	graph.neural.ClipValue = graph.ClipValue
+	graph.neural.ClipNorm = graph.ClipNorm
+	return graph.neural.Step()
+}
+
+// ZeroGrad clears the accumulated gradients of all layers
+func (graph *Graph) ZeroGrad() {
+	graph.neural.ZeroGrad()
+}
+
+// LearnRaw uses backpropagation (ZeroGrad, Forward, Backward and Step) with raw inputs and outputs by name
+// It returns NaN without learning after training was aborted (check Err)
+func (graph *Graph) LearnRaw(inputs map[string][]float64, outputs map[string][]float64) float64 {
+	if graph.neural.err != nil {
+		return math.NaN()
+	}
+
+	graph.ZeroGrad()
+	graph.forward(inputs, true)
+	loss := graph.Backward(outputs)
+
+	if err := graph.Step(); err != nil {
+		return math.NaN()
+	}
+
+	return loss
+}
+
+// Err is the error that aborted the training
+func (graph *Graph) Err() error {
+	return graph.neural.err
+}
+
+// Layers of the nodes in order
+func (graph *Graph) Layers() []*Layer {
+	return graph.neural.Layers
+}
+
+// NumParams is the amount of weights and biases of all layers
+func (graph *Graph) NumParams() int {
+	return graph.neural.NumParams()
+}
+
+// Params returns a copy of the weights and biases of all layers (in the order of the nodes)
+func (graph *Graph) Params() []float64 {
+	return graph.neural.Params()
+}
+
+// SetParams replaces the weights and biases of all layers
+func (graph *Graph) SetParams(params []float64) error {
+	return graph.neural.SetParams(params)
+}
+
+// Grads returns a copy of the gradients of all layers
+func (graph *Graph) Grads() []float64 {
+	return graph.neural.Grads()
+}
+
+// Seed sets a reproducible source of randomness for all layers
+func (graph *Graph) Seed(seed int64) {
+	graph.neural.Seed(seed)
+	graph.Random = graph.neural.Random
+}
+
+// Rate set the rate for all layers
+func (graph *Graph) Rate(value float64) {
+	graph.neural.Rate(value)
+}
+
+// Momentum set the momentum for all layers
+func (graph *Graph) Momentum(value float64) {
+	graph.neural.Momentum(value)
+}
+
+// Clone graph with same nodes, layers and heads
+func (graph *Graph) Clone() *Graph {
+	nodes := make([]*Node, len(graph.Nodes))
+	for n, node := range graph.Nodes {
+		nodes[n] = &Node{Name: node.Name, From: append([]string(nil), node.From...), Merge: node.Merge}
+		if node.Layer != nil {
+			nodes[n].Layer = node.Layer.Clone()
+		}
+	}
+
+	clone := &Graph{
+		Inputs:    append([]GraphInput(nil), graph.Inputs...),
+		Nodes:     nodes,
+		Heads:     append([]Head(nil), graph.Heads...),
+		Random:    graph.Random,
+		ClipValue: graph.ClipValue,
+		ClipNorm:  graph.ClipNorm,
+	}
+	if err := clone.link(false); err != nil {
+		panic(err.Error())
+	}
+	clone.neural.eval = graph.neural.eval
+
+	return clone
+}
+
+// Export graph to json string
+func (graph *Graph) Export() ([]byte, error) {
+	return json.Marshal(&graph)
+}
+
+// Import graph from json string
+func (graph *Graph) Import(encoded []byte) error {
+	graph.Inputs, graph.Nodes, graph.Heads = nil, nil, nil
+	if err := json.Unmarshal(encoded, &graph); err != nil {
+		return err
+	}
+
+	for _, node := range graph.Nodes {
+		if node.Layer == nil {
+			continue
+		}
+		if err := node.Layer.imported(graph.Random); err != nil {
+			return err
+		}
+	}
+
+	return graph.link(false)
+}
+
+// ToFile export graph to file
+func (graph *Graph) ToFile(filename string) error {
+	encoded, err := graph.Export()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, encoded, 0644)
+}
+
+// FromFile import graph from file
+func (graph *Graph) FromFile(filename string) error {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return graph.Import(content)
+}
@@ -0,0 +1,162 @@
+package neural
+
+import (
+	"reflect"
+	"testing"
+)
+
+// graphInputs are the raw values of the inputs a (3) and b (3)
+var graphInputs = map[string][]float64{"a": {0.3, -0.8, 0.5}, "b": {-0.2, 0.6, 0.9}}
+
+// graphTests are graphs with every merge, a residual block and weighted heads of both losses
+var graphTests = []struct {
+	name    string
+	nodes   func() []*Node
+	heads   []Head
+	outputs map[string][]float64
+}{
+	{"concat", func() []*Node {
+		return []*Node{
+			{Name: "x", From: []string{"a"}, Layer: &Layer{Units: 4, Activation: "tanh"}},
+			{Name: "y", From: []string{"x", "b"}, Layer: &Layer{Units: 2}},
+		}
+	}, []Head{{Node: "y"}}, map[string][]float64{"y": {0.2, 0.9}}},
+	{"add", func() []*Node {
+		return []*Node{
+			{Name: "x", From: []string{"a"}, Layer: &Layer{Units: 3, Activation: "tanh"}},
+			{Name: "sum", From: []string{"x", "b", "a"}, Merge: "add"},
+			{Name: "y", From: []string{"sum"}, Layer: &Layer{Units: 2}},
+		}
+	}, []Head{{Node: "y"}}, map[string][]float64{"y": {0.2, 0.9}}},
+	{"multiply", func() []*Node {
+		return []*Node{
+			{Name: "x", From: []string{"a"}, Layer: &Layer{Units: 3, Activation: "tanh"}},
+			{Name: "z", From: []string{"b"}, Layer: &Layer{Units: 3}},
+			{Name: "product", From: []string{"x", "z", "b"}, Merge: "multiply"},
+			{Name: "y", From: []string{"product"}, Layer: &Layer{Units: 2}},
+		}
+	}, []Head{{Node: "y"}}, map[string][]float64{"y": {0.2, 0.9}}},
+	{"residual", func() []*Node {
+		nodes := []*Node{{Name: "x", From: []string{"a", "b"}, Layer: &Layer{Units: 4, Activation: "tanh"}}}
+		nodes = append(nodes, Residual("block", "x", &Layer{Units: 5, Activation: "relu"}, &Layer{Type: "layernorm"}, &Layer{Units: 4, Activation: "tanh"})...)
+		return append(nodes, &Node{Name: "y", From: []string{"block"}, Layer: &Layer{Units: 2}})
+	}, []Head{{Node: "y"}}, map[string][]float64{"y": {0.2, 0.9}}},
+	{"weighted heads", func() []*Node {
+		return []*Node{
+			{Name: "x", From: []string{"a", "b"}, Layer: &Layer{Units: 4, Activation: "tanh"}},
+			{Name: "class", From: []string{"x"}, Layer: &Layer{Units: 2}},
+			{Name: "value", From: []string{"x", "a"}, Layer: &Layer{Units: 1, Activation: "linear"}},
+		}
+	}, []Head{{Node: "class", Loss: "crossentropy", Weight: 0.3}, {Node: "value", Weight: 2}}, map[string][]float64{"class": {1, 0}, "value": {0.7}}},
+}
+
+// graphOf creates the graph of a test
+func graphOf(nodes []*Node, heads []Head) *Graph {
+	graph := NewGraph([]GraphInput{{Name: "a", Size: 3}, {Name: "b", Size: 3}}, nodes, heads)
+	graph.Seed(1)
+	for _, layer := range graph.Layers() {
+		layer.Reset()
+	}
+	return graph
+}
+
+func TestGradCheckGraph(t *testing.T) {
+	for _, test := range graphTests {
+		t.Run(test.name, func(t *testing.T) {
+			graph := graphOf(test.nodes(), test.heads)
+
+			errors := GradCheckGraph(graph, graphInputs, test.outputs)
+			for i, err := range errors {
+				if err > gradTolerance {
+					t.Fatalf("layer %v has a relative error of %.2e, all layers %.2e", i, err, errors)
+				}
+			}
+		})
+	}
+}
+
+func TestGraphRoundTrip(t *testing.T) {
+	for _, test := range graphTests {
+		t.Run(test.name, func(t *testing.T) {
+			graph := graphOf(test.nodes(), test.heads)
+			graph.LearnRaw(graphInputs, test.outputs)
+
+			encoded, err := graph.Export()
+			if err != nil {
+				t.Fatal(err)
+			}
+			imported := &Graph{}
+			if err := imported.Import(encoded); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(imported.Params(), graph.Params()) {
+				t.Fatal("params changed")
+			}
+			if !reflect.DeepEqual(imported.ThinkRaw(graphInputs), graph.ThinkRaw(graphInputs)) {
+				t.Fatal("thinks different after import")
+			}
+		})
+	}
+}
+
+func TestGraphThinkBetweenForwardAndBackward(t *testing.T) {
+	test := graphTests[0]
+	graph := graphOf(test.nodes(), test.heads)
+	graph.Forward(graphInputs)
+	if outputs := graph.ThinkRaw(graphInputs); len(outputs["y"]) != 2 {
+		t.Fatalf("outputs %v", outputs)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic of Backward after thinking")
+		}
+	}()
+	graph.Backward(test.outputs)
+}
+
+func TestGraphImportErrors(t *testing.T) {
+	inputs := `"Inputs": [{"Name": "a", "Size": 2}]`
+	dense := `{"Neurons": [{"Weights": [0.1, 0.2], "Bias": 0}]}`
+
+	for name, encoded := range map[string]string{
+		"syntax":         `{"Inputs": [`,
+		"input size":     `{"Inputs": [{"Name": "a"}]}`,
+		"unknown source": `{` + inputs + `, "Nodes": [{"Name": "x", "From": ["b"]}]}`,
+		"repeated name":  `{` + inputs + `, "Nodes": [{"Name": "a", "From": ["a"]}]}`,
+		"merge":          `{` + inputs + `, "Nodes": [{"Name": "x", "From": ["a"], "Merge": "max"}]}`,
+		"layer inputs":   `{` + inputs + `, "Nodes": [{"Name": "x", "From": ["a", "a"], "Layer": ` + dense + `}]}`,
+		"layer type":     `{` + inputs + `, "Nodes": [{"Name": "x", "From": ["a"], "Layer": {"Type": "unknown"}}]}`,
+		"no neurons":     `{` + inputs + `, "Nodes": [{"Name": "x", "From": ["a"], "Layer": {"Type": "lstm"}}]}`,
+		"head":           `{` + inputs + `, "Nodes": [{"Name": "x", "From": ["a"], "Layer": ` + dense + `}], "Heads": [{"Node": "y"}]}`,
+		"loss":           `{` + inputs + `, "Nodes": [{"Name": "x", "From": ["a"], "Layer": ` + dense + `}], "Heads": [{"Node": "x", "Loss": "hinge"}]}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			if err := (&Graph{}).Import([]byte(encoded)); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestGraphLayersUseItsRandom(t *testing.T) {
+	create := func() *Graph {
+		test := graphTests[3]
+		graph := &Graph{Inputs: []GraphInput{{Name: "a", Size: 3}, {Name: "b", Size: 3}}, Nodes: test.nodes(), Heads: test.heads, Random: NewRandom(7)}
+		if err := graph.link(true); err != nil {
+			t.Fatal(err)
+		}
+		return graph
+	}
+
+	graph := create()
+	for _, layer := range graph.Layers() {
+		if layer.Random != graph.Random {
+			t.Fatal("a layer doesn't use the random source of the graph")
+		}
+	}
+	if !reflect.DeepEqual(graph.Params(), create().Params()) {
+		t.Fatal("the same random source created different params")
+	}
+}
@@ -0,0 +1,290 @@
+package neural
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// Kind is a type of layer, every layer has its own value of its kind (see RegisterKind) with the params and state
+// that the definition of the Layer doesn't have, so a new type of layer doesn't need new fields in Layer
+// Kinds that keep their params in the neurons of the layer (weights + bias) embed NeuronKind, dense is the default kind
+type Kind interface {
+	// New checks the definition and creates the params, Inputs is already set and Units may be set
+	New(layer *Layer)
+	// Forward writes the outputs (before dropout) in outs and keeps what the backward needs, training is false for inference
+	Forward(layer *Layer, inputs []float64, outs []float64, training bool)
+	// Backward adds the gradients based on the errors of the outputs and returns the errors of the inputs
+	Backward(layer *Layer, errors []float64) []float64
+	// Params of the units in order (all of them if units is nil), every Param points to where the kind keeps it
+	Params(layer *Layer, units []int) []Param
+	// State is what the last forward keeps for the backward and SetState restores it (sequences backpropagate every step)
+	State(layer *Layer) interface{}
+	SetState(layer *Layer, state interface{})
+	// Clone copies the params and what the kind keeps to clone, a new layer with the same definition
+	Clone(layer *Layer, clone *Layer)
+	// Mutate changes the params randomly based on probability
+	Mutate(layer *Layer, probability float64)
+	// Crossover sets the params of child, a new layer with the same definition, merging the ones of layer and other
+	Crossover(layer *Layer, other *Layer, child *Layer, dominant float64)
+	// Export is what the json of the layer keeps of the kind besides the definition and neurons (nil if nothing)
+	Export(layer *Layer) (json.RawMessage, error)
+	// Import restores a layer decoded from json with the data of Export (definition and neurons are already there),
+	// it sets the inputs and units
+	Import(layer *Layer, data json.RawMessage) error
+	// Reset randomizes the params and clears what the layer keeps (statistics, states, etc)
+	Reset(layer *Layer)
+}
+
+// The optional interfaces change what a layer of a kind does besides its params, kinds without them work like dense
+
+// SparseKind only updates the units with gradients since the last ZeroGrad (like the vectors of embedding)
+type SparseKind interface {
+	// Rows are the indices of those neurons in order
+	Rows(layer *Layer) []int
+	// SetRows replaces them (none after ZeroGrad, the ones with gradients after the average of a batch)
+	SetRows(layer *Layer, rows []int)
+}
+
+// ResizeKind changes the amount of inputs keeping the weights that still fit (surgery reconnecting layers)
+// If the units change too (like normalization) the next layer is resized
+type ResizeKind interface {
+	Resize(layer *Layer, inputs int)
+}
+
+// WidenKind grows the units with copies of other units keeping the same outputs (Widen)
+type WidenKind interface {
+	// Elementwise is true if every unit only depends on its input (like batchnorm), so they grow with the previous layer
+	Elementwise(layer *Layer) bool
+	// WidenUnits appends a copy of the unit sources[u] for every new unit u (and of its input if elementwise)
+	WidenUnits(layer *Layer, sources []int)
+	// WidenInputs grows the inputs to len(sources) dividing the weights of every copied input by its amount of copies
+	WidenInputs(layer *Layer, sources []int, copies []int)
+}
+
+// SyncKind copies what a layer keeps besides params and config (like running statistics) from the master to the replicas of Workers
+type SyncKind interface {
+	Sync(layer *Layer, master *Layer)
+}
+
+// BatchKind depends on all the samples of a mini-batch (like the statistics of batchnorm)
+// Workers run a forward of every sample up to the layer for SetBatch and later a backward of every sample for SetBatchErrors
+type BatchKind interface {
+	// Batched is true if the layer uses the mini-batch (only with 2 samples at least)
+	Batched(layer *Layer) bool
+	// SetBatch receives the inputs of the layer in every sample
+	SetBatch(layer *Layer, inputs [][]float64)
+	// BatchErrors is what the backward of a sample gives to SetBatchErrors (of every sample) before the last backward
+	BatchErrors(layer *Layer) []float64
+	SetBatchErrors(layer *Layer, errors [][]float64)
+	// Sample is what a replica keeps of the last forward for EndBatch, the master receives them in the order of the samples
+	Sample(layer *Layer) []float64
+	EndBatch(layer *Layer, samples [][]float64)
+}
+
+// kinds creates the value of the kind of every layer by the type used in the definition and export
+var kinds = map[string]func() Kind{
+	"dense":       func() Kind { return &denseKind{} },
+	"batchnorm":   func() Kind { return &batchNormKind{} },
+	"layernorm":   func() Kind { return &normKind{} },
+	"rnn":         func() Kind { return &recurrentKind{} },
+	"lstm":        func() Kind { return &recurrentKind{} },
+	"gru":         func() Kind { return &recurrentKind{} },
+	"conv1d":      func() Kind { return &spatialKind{} },
+	"conv2d":      func() Kind { return &spatialKind{} },
+	"maxpool":     func() Kind { return &spatialKind{} },
+	"avgpool":     func() Kind { return &spatialKind{} },
+	"flatten":     func() Kind { return &spatialKind{} },
+	"embedding":   func() Kind { return &embeddingKind{} },
+	"transformer": func() Kind { return &transformerKind{} },
+	"positional":  func() Kind { return &transformerKind{} },
+}
+
+var kindsMutex sync.RWMutex
+
+// RegisterKind adds a new type of layer (or replaces one), create returns a new value of the kind for every layer
+// Call it before creating or importing layers of that type, it's safe to call while other layers are created
+func RegisterKind(name string, create func() Kind) {
+	if name == "" || create == nil {
+		panic("need a name and a kind to register a layer type")
+	}
+
+	kindsMutex.Lock()
+	defer kindsMutex.Unlock()
+	kinds[name] = create
+}
+
+// newKind creates the value of a kind for a layer (nil if the type is not registered)
+func newKind(name string) Kind {
+	kindsMutex.RLock()
+	create := kinds[name]
+	kindsMutex.RUnlock()
+
+	if create == nil {
+		return nil
+	}
+	return create()
+}
+
+// kind of the layer (nil before NewLayer or Import)
+func (layer *Layer) kind() Kind {
+	return layer.instance
+}
+
+// sparse is the SparseKind of the layer (nil if it updates all neurons)
+func (layer *Layer) sparse() SparseKind {
+	sparse, _ := layer.kind().(SparseKind)
+	return sparse
+}
+
+// batched is the BatchKind of the layer (nil if it doesn't depend on mini-batches)
+func (layer *Layer) batched() BatchKind {
+	batch, _ := layer.kind().(BatchKind)
+	return batch
+}
+
+// widener is the WidenKind of the layer (nil if it can't widen)
+func (layer *Layer) widener() WidenKind {
+	widen, _ := layer.kind().(WidenKind)
+	return widen
+}
+
+// NeuronKind is the part of a Kind for the params kept in the neurons of the layer (weights + bias, exported as Neurons),
+// embed it and write New, Forward and Backward. Its state is the inputs and activations of the neurons
+type NeuronKind struct{}
+
+// Params are the weights and bias of the neurons of the units
+func (NeuronKind) Params(layer *Layer, units []int) []Param {
+	return layer.neuronParams(units, true)
+}
+
+// State is the inputs and activations of the neurons
+func (NeuronKind) State(layer *Layer) interface{} {
+	return layer.neuronState()
+}
+
+// SetState restores the inputs and activations of the neurons
+func (NeuronKind) SetState(layer *Layer, state interface{}) {
+	layer.setNeuronState(state.(neuronState))
+}
+
+// Clone copies the neurons
+func (NeuronKind) Clone(layer *Layer, clone *Layer) {
+	for i := range clone.Neurons {
+		clone.Neurons[i] = layer.Neurons[i].Clone()
+		clone.Neurons[i].Layer = clone
+	}
+}
+
+// Mutate every neuron
+func (NeuronKind) Mutate(layer *Layer, probability float64) {
+	for _, neuron := range layer.Neurons {
+		neuron.Mutate(probability)
+	}
+}
+
+// Crossover every neuron
+func (NeuronKind) Crossover(layer *Layer, other *Layer, child *Layer, dominant float64) {
+	for i := range child.Neurons {
+		child.Neurons[i] = layer.Neurons[i].Crossover(*other.Neurons[i], dominant)
+		child.Neurons[i].Layer = child
+	}
+}
+
+// Export has nothing besides the neurons
+func (NeuronKind) Export(layer *Layer) (json.RawMessage, error) {
+	return nil, nil
+}
+
+// Import links the neurons, the inputs are their weights and the units are the neurons (like dense)
+func (NeuronKind) Import(layer *Layer, data json.RawMessage) error {
+	for _, neuron := range layer.Neurons {
+		neuron.MaxInputs = len(neuron.Weights)
+		neuron.Layer = layer
+		neuron.Inputs = make([]float64, neuron.MaxInputs)
+
+		if len(neuron.Momentums) != neuron.MaxInputs+1 {
+			neuron.Momentums = make([]float64, neuron.MaxInputs+1)
+		}
+		neuron.Gradients = make([]float64, neuron.MaxInputs+1)
+	}
+
+	if len(layer.Neurons) > 0 {
+		layer.Inputs = len(layer.Neurons[0].Weights)
+		layer.Units = len(layer.Neurons)
+	}
+	return nil
+}
+
+// Reset every neuron
+func (NeuronKind) Reset(layer *Layer) {
+	for _, neuron := range layer.Neurons {
+		neuron.Reset()
+	}
+}
+
+type denseKind struct {
+	NeuronKind
+}
+
+func (*denseKind) New(layer *Layer) {
+	layer.Neurons = make([]*Neuron, layer.Units)
+	for i := 0; i < layer.Units; i++ {
+		layer.Neurons[i] = NewNeuron(layer, layer.Inputs)
+	}
+}
+
+func (*denseKind) Forward(layer *Layer, inputs []float64, outs []float64, training bool) {
+	for i := 0; i < layer.Units; i++ {
+		outs[i] = layer.Neurons[i].Think(inputs)
+	}
+}
+
+func (*denseKind) Backward(layer *Layer, errors []float64) []float64 {
+	inputErrors := make([]float64, layer.Inputs)
+
+	for n, neuron := range layer.Neurons {
+		neuron.error = errors[n]
+		neuron.delta = layer.Backward(neuron.activation) * neuron.error
+
+		for w := 0; w < neuron.MaxInputs; w++ {
+			inputErrors[w] += neuron.Weights[w] * neuron.delta
+			neuron.Gradients[w] -= neuron.Inputs[w] * neuron.delta
+		}
+		neuron.Gradients[neuron.MaxInputs] -= neuron.delta
+	}
+
+	return inputErrors
+}
+
+func (*denseKind) Resize(layer *Layer, inputs int) {
+	for _, neuron := range layer.Neurons {
+		resized := NewNeuron(layer, inputs)
+		copy(resized.Weights, neuron.Weights)
+		neuron.Weights = resized.Weights
+		neuron.MaxInputs = inputs
+		neuron.Momentums = resized.Momentums
+		neuron.Gradients = resized.Gradients
+		neuron.Inputs = resized.Inputs
+	}
+
+	layer.Inputs = inputs
+}
+
+func (*denseKind) Elementwise(layer *Layer) bool {
+	return false
+}
+
+func (*denseKind) WidenUnits(layer *Layer, sources []int) {
+	layer.widenNeurons(sources)
+}
+
+func (kind *denseKind) WidenInputs(layer *Layer, sources []int, copies []int) {
+	for _, neuron := range layer.Neurons {
+		weights := make([]float64, len(sources))
+		for u, source := range sources {
+			weights[u] = neuron.Weights[source] / float64(copies[source])
+		}
+		neuron.Weights = weights
+	}
+	kind.Resize(layer, len(sources))
+}
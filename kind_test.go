@@ -0,0 +1,307 @@
+package neural
+
+import (
+	"encoding/json"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// scaleKind is a kind outside of the package that keeps its params and state itself (no neurons):
+// every unit is activation(gain * gamma * (x - mean) + beta) of its input, gain is in the config of the layer,
+// the mean of the inputs of every batch is kept as a running mean and exported with gamma and beta
+type scaleKind struct {
+	gamma []float64
+	beta  []float64
+	// Gradient and momentum of every gamma followed by every beta
+	gradients []float64
+	momentums []float64
+	mean      []float64
+	last      scaleStep
+}
+
+type scaleStep struct {
+	inputs  []float64
+	outputs []float64
+}
+
+type scaleExport struct {
+	Gamma []float64
+	Beta  []float64
+	Mean  []float64
+}
+
+func (kind *scaleKind) New(layer *Layer) {
+	layer.Units = layer.Inputs
+	kind.allocate(layer.Units)
+	kind.Reset(layer)
+}
+
+func (kind *scaleKind) allocate(units int) {
+	kind.gamma, kind.beta, kind.mean = make([]float64, units), make([]float64, units), make([]float64, units)
+	kind.gradients, kind.momentums = make([]float64, 2*units), make([]float64, 2*units)
+}
+
+func (kind *scaleKind) Forward(layer *Layer, inputs []float64, outs []float64, training bool) {
+	step := scaleStep{make([]float64, layer.Units), make([]float64, layer.Units)}
+	for i := range kind.gamma {
+		step.inputs[i] = inputs[i] - kind.mean[i]
+		outs[i] = layer.Forward(layer.Config["gain"]*kind.gamma[i]*step.inputs[i] + kind.beta[i])
+		step.outputs[i] = outs[i]
+	}
+	kind.last = step
+}
+
+func (kind *scaleKind) Backward(layer *Layer, errors []float64) []float64 {
+	inputErrors := make([]float64, layer.Inputs)
+	for i, gamma := range kind.gamma {
+		delta := layer.Backward(kind.last.outputs[i]) * errors[i]
+		kind.gradients[i] -= layer.Config["gain"] * kind.last.inputs[i] * delta
+		kind.gradients[layer.Units+i] -= delta
+		inputErrors[i] = layer.Config["gain"] * gamma * delta
+	}
+	return inputErrors
+}
+
+func (kind *scaleKind) Params(layer *Layer, units []int) []Param {
+	params := []Param{}
+	for u := range kind.gamma {
+		if units != nil && !containsInt(units, u) {
+			continue
+		}
+		b := layer.Units + u
+		params = append(params, Param{&kind.gamma[u], &kind.gradients[u], &kind.momentums[u], u, false})
+		params = append(params, Param{&kind.beta[u], &kind.gradients[b], &kind.momentums[b], u, true})
+	}
+	return params
+}
+
+func (kind *scaleKind) State(layer *Layer) interface{} {
+	return kind.last
+}
+
+func (kind *scaleKind) SetState(layer *Layer, state interface{}) {
+	kind.last = state.(scaleStep)
+}
+
+func (kind *scaleKind) Clone(layer *Layer, clone *Layer) {
+	into := clone.kind().(*scaleKind)
+	copy(into.gamma, kind.gamma)
+	copy(into.beta, kind.beta)
+	copy(into.mean, kind.mean)
+}
+
+func (kind *scaleKind) Mutate(layer *Layer, probability float64) {
+	for i := range kind.gamma {
+		if probability >= layer.Random.Float64() {
+			kind.gamma[i] += layer.Random.Range(-1.0, 1.0)
+		}
+	}
+}
+
+func (kind *scaleKind) Crossover(layer *Layer, other *Layer, child *Layer, dominant float64) {
+	into, from := child.kind().(*scaleKind), other.kind().(*scaleKind)
+	for i := range kind.gamma {
+		into.gamma[i], into.beta[i] = from.gamma[i], from.beta[i]
+		if layer.Random.Float64() >= 0.5 {
+			into.gamma[i], into.beta[i] = kind.gamma[i], kind.beta[i]
+		}
+		into.mean[i] = (kind.mean[i] + from.mean[i]) / 2.0
+	}
+}
+
+func (kind *scaleKind) Export(layer *Layer) (json.RawMessage, error) {
+	return json.Marshal(scaleExport{kind.gamma, kind.beta, kind.mean})
+}
+
+func (kind *scaleKind) Import(layer *Layer, data json.RawMessage) error {
+	exported := scaleExport{}
+	if err := json.Unmarshal(data, &exported); err != nil {
+		return err
+	}
+	kind.allocate(len(exported.Gamma))
+	copy(kind.gamma, exported.Gamma)
+	copy(kind.beta, exported.Beta)
+	copy(kind.mean, exported.Mean)
+	layer.Units = len(kind.gamma)
+	layer.Inputs = layer.Units
+	return nil
+}
+
+func (kind *scaleKind) Reset(layer *Layer) {
+	for i := range kind.gamma {
+		kind.gamma[i] = layer.Random.Range(0.5, 1.5)
+		kind.beta[i] = layer.Random.Range(-0.5, 0.5)
+		kind.mean[i] = 0.0
+	}
+}
+
+func (kind *scaleKind) Sync(layer *Layer, master *Layer) {
+	copy(kind.mean, master.kind().(*scaleKind).mean)
+}
+
+func (*scaleKind) Batched(layer *Layer) bool {
+	return false
+}
+
+func (*scaleKind) SetBatch(layer *Layer, inputs [][]float64) {}
+
+func (*scaleKind) BatchErrors(layer *Layer) []float64 {
+	return nil
+}
+
+func (*scaleKind) SetBatchErrors(layer *Layer, errors [][]float64) {}
+
+func (kind *scaleKind) Sample(layer *Layer) []float64 {
+	inputs := make([]float64, layer.Units)
+	for i := range inputs {
+		inputs[i] = kind.last.inputs[i] + kind.mean[i]
+	}
+	return inputs
+}
+
+func (kind *scaleKind) EndBatch(layer *Layer, samples [][]float64) {
+	for i := range kind.mean {
+		mean := 0.0
+		for _, sample := range samples {
+			mean += sample[i] / float64(len(samples))
+		}
+		kind.mean[i] += 0.5 * (mean - kind.mean[i])
+	}
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// scaleNeural has a scale layer between dense ones
+func scaleNeural() *Neural {
+	RegisterKind("scale", func() Kind { return &scaleKind{} })
+
+	neural := NewNeural([]*Layer{
+		{Inputs: 3, Units: 4, Activation: "tanh"},
+		{Type: "scale", Activation: "tanh", Config: map[string]float64{"gain": 1.5}},
+		{Units: 1},
+	})
+	neural.Seed(1)
+	neural.Reset()
+	return neural
+}
+
+func TestKindGradients(t *testing.T) {
+	checkGradients(t, scaleNeural(), []float64{0.3, -0.8, 0.5}, []float64{0.2})
+
+	// the state of every step is kept to backpropagate sequences
+	neural := NewNeural([]*Layer{
+		{Inputs: 2, Units: 3, Type: "rnn"},
+		{Type: "scale", Activation: "tanh", Config: map[string]float64{"gain": 0.5}},
+		{Units: 2},
+	})
+	neural.Seed(1)
+	neural.Reset()
+	checkSequenceGradients(t, neural, sequence, [][]float64{{0.9, 0.1}, {0.2, 0.7}, {0.5, 0.5}, {0.1, 0.3}, {0.8, 0.6}})
+}
+
+func TestKindLearns(t *testing.T) {
+	neural := scaleNeural()
+	before := neural.Layers[1].Params()
+
+	neural.LearnRaw([]float64{0.3, -0.8, 0.5}, []float64{0.2})
+	if reflect.DeepEqual(neural.Layers[1].Params(), before) || len(before) != 8 {
+		t.Fatalf("params of the kind %v didn't learn", before)
+	}
+}
+
+func TestKindSyncsWorkers(t *testing.T) {
+	dataset := batchDataset()
+
+	neural := scaleNeural()
+	neural.Batch, neural.Workers = 3, 2
+	neural.LearnsRaw(dataset)
+
+	// replicas created again on every batch have the running mean of the master
+	expected := scaleNeural()
+	expected.Batch, expected.Workers = 3, 2
+	for from := 0; from < len(dataset); from += 3 {
+		expected.replicas = nil
+		expected.LearnsRaw(dataset[from : from+3])
+	}
+
+	mean := neural.Layers[1].kind().(*scaleKind).mean
+	if !reflect.DeepEqual(neural.Params(), expected.Params()) || !reflect.DeepEqual(mean, expected.Layers[1].kind().(*scaleKind).mean) {
+		t.Fatal("the replicas didn't sync with the master")
+	}
+	if mean[0] == 0.0 {
+		t.Fatal("the batches didn't end")
+	}
+}
+
+func TestKindConfig(t *testing.T) {
+	neural := scaleNeural()
+	imported := roundTrip(t, neural)
+	clone := neural.Clone()
+	neural.Layers[1].Config["gain"] = 2.0
+
+	for _, layer := range []*Layer{imported.Layers[1], clone.Layers[1]} {
+		if layer.Type != "scale" || layer.Config["gain"] != 1.5 {
+			t.Fatalf("config of the kind %v %v", layer.Type, layer.Config)
+		}
+	}
+}
+
+func TestKindOwnsItsParams(t *testing.T) {
+	neural := scaleNeural()
+	neural.LearnsRaw(batchDataset())
+	layer := neural.Layers[1]
+	layer.kind().(*scaleKind).mean[0] = 0.25
+
+	// export, import and clone keep the params and the running mean of every layer in its own kind
+	for _, copied := range []*Neural{roundTrip(t, neural), neural.Clone()} {
+		kind := copied.Layers[1].kind().(*scaleKind)
+		if kind == layer.kind() || !reflect.DeepEqual(copied.Params(), neural.Params()) || kind.mean[0] != 0.25 {
+			t.Fatalf("params %v mean %v", copied.Layers[1].Params(), kind.mean)
+		}
+		if len(copied.Layers[1].Neurons) != 0 || copied.Layers[1].Units != 4 {
+			t.Fatal("the kind has neurons")
+		}
+	}
+
+	// crossover takes every unit from a parent, mutation changes the params of the kind
+	other := scaleNeural()
+	other.Layers[1].SetParams([]float64{9, 9, 9, 9, 9, 9, 9, 9})
+	child := layer.Crossover(other.Layers[1], 0.5)
+	for i, param := range child.Params() {
+		if param != 9 && param != layer.Params()[i] {
+			t.Fatalf("param %v of the child %v", i, param)
+		}
+	}
+	if child.kind().(*scaleKind).mean[0] != 0.125 {
+		t.Fatalf("mean of the child %v", child.kind().(*scaleKind).mean)
+	}
+
+	params := layer.Params()
+	layer.Mutate(1.0)
+	if reflect.DeepEqual(layer.Params(), params) {
+		t.Fatal("mutation didn't change the params")
+	}
+}
+
+func TestRegisterKindConcurrently(t *testing.T) {
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				RegisterKind("scale", func() Kind { return &scaleKind{} })
+				NewLayer(&Layer{Type: "scale", Inputs: 2, Config: map[string]float64{"gain": 1}})
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -1,7 +1,16 @@
 package neural
 
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
 // Layer is a set of neurons + config
 type Layer struct {
+	// Default type is dense, others are batchnorm, layernorm, rnn, lstm, gru, conv1d, conv2d, maxpool, avgpool, flatten,
+	// embedding, positional, transformer and the ones added by RegisterKind
+	Type string `json:"Type,omitempty"`
 	// Amount of inputs (default is previous layer units)
 	Inputs  int       `json:"-"`
 	Units   int       `json:"-"`
@@ -13,12 +22,66 @@ type Layer struct {
 	// Default loss is mse
 	Loss   string `json:"Loss,omitempty"`
 	LossFn LossFn `json:"-"`
-	// Default rate is 0.001
+	// Default rate is 0.001 (the Scheduler multiplies it)
 	Rate float64 `json:"-"`
 	// Default momentum is 0.999
 	Momentum float64 `json:"-"`
+	// Regularization of weights (default is none), L1 and L2 together are elastic-net
+	L1 float64 `json:"-"`
+	L2 float64 `json:"-"`
+	// Decoupled weight decay, weights shrink by rate * decay on every update
+	WeightDecay float64 `json:"-"`
+	// Also regularize the bias and the gamma and beta of normalizations (default is only weights)
+	RegularizeBias bool `json:"-"`
+	// Frozen layers still backpropagate but their weights don't change (learning, mutation or crossover)
+	Frozen bool `json:"Frozen,omitempty"`
+	// Probability of dropping every output while learning (hidden layers only)
+	Dropout float64 `json:"Dropout,omitempty"`
+	// Use alpha-dropout (keeps mean and variance of selu) instead of standard dropout
+	AlphaDropout bool `json:"AlphaDropout,omitempty"`
+	// Recurrent layers keep the hidden state between sequences (default resets it on every sequence)
+	Stateful bool `json:"Stateful,omitempty"`
+	// Shape of the inputs of spatial layers (default is the output shape of the previous layer)
+	Channels int `json:"Channels,omitempty"`
+	Height   int `json:"Height,omitempty"`
+	Width    int `json:"Width,omitempty"`
+	// Filters of convolutions (output channels) and size of the window of convolutions and pooling
+	Filters int `json:"Filters,omitempty"`
+	Kernel  int `json:"Kernel,omitempty"`
+	// Default stride is 1 for convolutions and the kernel size for pooling, default dilation is 1
+	Stride   int `json:"Stride,omitempty"`
+	Padding  int `json:"Padding,omitempty"`
+	Dilation int `json:"Dilation,omitempty"`
+	// Amount of tokens of embedding (indices), positional and transformer layers (default follows the inputs)
+	Sequence int `json:"Sequence,omitempty"`
+	// Amount of indices of embedding layers and values of every token
+	Vocabulary int `json:"Vocabulary,omitempty"`
+	Dimensions int `json:"Dimensions,omitempty"`
+	// Attention heads (default is 1) and feed-forward units (default is 4 * dimensions) of transformer layers
+	Heads  int `json:"Heads,omitempty"`
+	Hidden int `json:"Hidden,omitempty"`
+	// Running statistics of batchnorm layers
+	Mean     []float64 `json:"Mean,omitempty"`
+	Variance []float64 `json:"Variance,omitempty"`
 	// Range of arbitrary values for input/output layers
 	Range [][]float64 `json:"Range,omitempty"`
+	// Scalers of arbitrary values for input/output layers (instead of range)
+	Scalers []Scaler `json:"Scalers,omitempty"`
+	// Config of the kinds added by RegisterKind
+	Config map[string]float64 `json:"Config,omitempty"`
+	// Source of randomness (default is crypto/rand)
+	Random *Random `json:"-"`
+	// Value of the kind of the layer with what the definition doesn't have (see Kind)
+	instance Kind
+	// What the json of the layer keeps of the kind until it's imported (see Kind.Export)
+	data json.RawMessage
+	// Rate before scheduling and the last one set by the scheduler (a different Rate was set by hand)
+	baseRate      float64
+	scheduledRate float64
+	// Multiplier of every output by the dropout of the last forward
+	mask []float64
+	// Replicas of workers don't change their running statistics, the master does it in order (see parallel)
+	replica bool
 }
 
 // NewLayer creates a layer based on simple layer definition
@@ -31,10 +94,15 @@ func NewLayer(layer *Layer) *Layer {
 		layer.Momentum = 0.999
 	}
 
-	layer.Neurons = make([]*Neuron, layer.Units)
-	for i := 0; i < layer.Units; i++ {
-		layer.Neurons[i] = NewNeuron(layer, layer.Inputs)
+	if layer.Type == "" {
+		layer.Type = "dense"
+	}
+
+	layer.instance = newKind(layer.Type)
+	if layer.instance == nil {
+		panic("need a valid layer type")
 	}
+	layer.instance.New(layer)
 
 	activation := layer.SetActivation(layer.Activation)
 
@@ -51,69 +119,333 @@ func NewLayer(layer *Layer) *Layer {
 
 // Think process the layer forward based on inputs
 func (layer *Layer) Think(inputs []float64) []float64 {
+	return layer.forward(inputs, false)
+}
+
+// forward process the layer in training (random dropout) or inference mode
+func (layer *Layer) forward(inputs []float64, training bool) []float64 {
 	outs := make([]float64, layer.Units)
+	layer.kind().Forward(layer, inputs, outs, training)
 
-	for i := 0; i < layer.Units; i++ {
-		outs[i] = layer.Neurons[i].Think(inputs)
+	layer.mask = nil
+	if layer.Dropout > 0.0 {
+		layer.dropout(outs, training)
 	}
 
 	return outs
 }
 
-// Clone layer with same neurons, activation, range, etc
-func (layer *Layer) Clone() *Layer {
-	clone := NewLayer(&Layer{
-		Inputs:     layer.Inputs,
-		Units:      layer.Units,
-		Activation: layer.Activation,
-		Rate:       layer.Rate,
-		Momentum:   layer.Momentum,
-	})
+// dropout drops outputs randomly while training
+// Standard dropout scales the outputs by the keep probability on inference
+// Alpha-dropout sets dropped outputs to the selu saturation and fixes mean/variance by an affine transform
+func (layer *Layer) dropout(outs []float64, training bool) {
+	keep := 1.0 - layer.Dropout
+
+	if !training {
+		if !layer.AlphaDropout {
+			layer.mask = make([]float64, len(outs))
+			for i := range outs {
+				layer.mask[i] = keep
+				outs[i] *= keep
+			}
+		}
+		return
+	}
+
+	layer.mask = make([]float64, len(outs))
+
+	if !layer.AlphaDropout {
+		for i := range outs {
+			if layer.Random.Float64() < keep {
+				layer.mask[i] = 1.0
+			}
+			outs[i] *= layer.mask[i]
+		}
+		return
+	}
+
+	saturation := -seluScale * seluAlpha
+	a := 1.0 / math.Sqrt(keep*(1.0+layer.Dropout*saturation*saturation))
+	b := -a * saturation * layer.Dropout
+
+	for i := range outs {
+		if layer.Random.Float64() < keep {
+			layer.mask[i] = a
+			outs[i] = a*outs[i] + b
+		} else {
+			outs[i] = a*saturation + b
+		}
+	}
+}
+
+// backward adds the gradients based on the errors of the outputs (target - output)
+// It returns the errors of the inputs to continue the backpropagation
+func (layer *Layer) backward(errors []float64) []float64 {
+	if layer.mask != nil {
+		masked := make([]float64, len(errors))
+		for i := range errors {
+			masked[i] = errors[i] * layer.mask[i]
+		}
+		errors = masked
+	}
+
+	return layer.kind().Backward(layer, errors)
+}
+
+// paramUpdate is the new value and momentum of a param, applied when every update of the step is finite
+type paramUpdate struct {
+	param    Param
+	value    float64
+	momentum float64
+}
+
+// apply the update to the param
+func (update paramUpdate) apply() {
+	*update.param.Value = update.value
+	*update.param.Momentum = update.momentum
+}
+
+// updates of the params descending the gradients (plus regularization), nothing changes until they are applied
+// It returns an error when a param wouldn't be finite anymore
+func (layer *Layer) updates() ([]paramUpdate, *DivergedError) {
+	if layer.Frozen {
+		return nil, nil
+	}
+
+	var rows []int
+	if sparse := layer.sparse(); sparse != nil {
+		rows = append([]int{}, sparse.Rows(layer)...)
+	}
+
+	params := layer.params(rows)
+	updates := make([]paramUpdate, len(params))
+	for p, param := range params {
+		updates[p] = layer.step(param)
+		if !isFinite(updates[p].value) || !isFinite(updates[p].momentum) {
+			return nil, &DivergedError{Neuron: param.Unit}
+		}
+	}
+
+	return updates, nil
+}
 
-	for i := 0; i < clone.Units; i++ {
-		clone.Neurons[i] = layer.Neurons[i].Clone()
+// zeroGrad clears the gradients of the layer (only the rows of sparse kinds)
+func (layer *Layer) zeroGrad() {
+	var rows []int
+	if sparse := layer.sparse(); sparse != nil {
+		rows = append([]int{}, sparse.Rows(layer)...)
+		sparse.SetRows(layer, nil)
 	}
 
+	for _, param := range layer.params(rows) {
+		*param.Gradient = 0.0
+	}
+}
+
+// step is the new value and momentum of a param descending its gradient, biases are only regularized with RegularizeBias
+func (layer *Layer) step(param Param) paramUpdate {
+	value, gradient := *param.Value, *param.Gradient
+	regularized := !param.Bias || layer.RegularizeBias
+
+	if regularized {
+		gradient += layer.L1*sign(value) + layer.L2*value
+	}
+	momentum := -gradient*layer.Rate + layer.Momentum*(*param.Momentum)
+
+	update := paramUpdate{param, value + momentum, momentum}
+	if regularized {
+		update.value -= layer.Rate * layer.WeightDecay * value
+	}
+	return update
+}
+
+// penalty is the regularization term (L1 and L2) of the layer
+func (layer *Layer) penalty() float64 {
+	if layer.L1 == 0.0 && layer.L2 == 0.0 {
+		return 0.0
+	}
+
+	penalty := 0.0
+	for _, param := range layer.params(nil) {
+		if !param.Bias || layer.RegularizeBias {
+			penalty += layer.L1*math.Abs(*param.Value) + 0.5*layer.L2*(*param.Value)*(*param.Value)
+		}
+	}
+	return penalty
+}
+
+// Clone layer with same neurons, activation, range, etc
+func (layer *Layer) Clone() *Layer {
+	clone := NewLayer(layer.definition())
+	layer.kind().Clone(layer, clone)
+
 	clone.Range = make([][]float64, len(layer.Range))
 	copy(clone.Range, layer.Range)
+	clone.Scalers = append([]Scaler(nil), layer.Scalers...)
 
 	return clone
 }
 
-// Mutate neurons of layer based on probability
+// Mutate params of layer based on probability
 func (layer *Layer) Mutate(probability float64) {
-	for i := 0; i < layer.Units; i++ {
-		layer.Neurons[i].Mutate(probability)
+	if layer.Frozen {
+		return
 	}
+
+	layer.kind().Mutate(layer, probability)
 }
 
 // Crossover two layers merging neurons
 func (layer *Layer) Crossover(layerB *Layer, dominant float64) *Layer {
-	new := NewLayer(&Layer{
-		Inputs:     layer.Inputs,
-		Units:      layer.Units,
-		Activation: layer.Activation,
-		Rate:       layer.Rate,
-		Momentum:   layer.Momentum,
-	})
-
-	for i := 0; i < layer.Units; i++ {
-		new.Neurons[i] = layer.Neurons[i].Crossover(*layerB.Neurons[i], dominant)
+	if layer.Frozen {
+		return layer.Clone()
 	}
 
+	new := NewLayer(layer.definition())
+	layer.kind().Crossover(layer, layerB, new, dominant)
+
 	new.Range = make([][]float64, len(layer.Range))
 	copy(new.Range, layer.Range)
+	new.Scalers = append([]Scaler(nil), layer.Scalers...)
 
 	return new
 }
 
-// Reset every neuron (weights, bias, etc)
+// Reset the params (weights, bias, etc) and what the kind of layer keeps
 func (layer *Layer) Reset() {
-	for i := 0; i < layer.Units; i++ {
-		layer.Neurons[i].Reset()
+	layer.kind().Reset(layer)
+}
+
+// layerShape is what makes two layers of the same type have different params or outputs
+type layerShape struct {
+	Type                                                      string
+	Inputs, Units, Params                                     int
+	Channels, Height, Width, Filters, Kernel, Stride, Padding int
+	Dilation, Sequence, Vocabulary, Dimensions, Heads, Hidden int
+}
+
+// shape of the layer
+func (layer *Layer) shape() layerShape {
+	return layerShape{
+		layer.Type, layer.Inputs, layer.Units, layer.NumParams(),
+		layer.Channels, layer.Height, layer.Width, layer.Filters, layer.Kernel, layer.Stride, layer.Padding,
+		layer.Dilation, layer.Sequence, layer.Vocabulary, layer.Dimensions, layer.Heads, layer.Hidden,
 	}
 }
 
+// definition is the simple layer definition (config without neurons) to create a similar layer
+func (layer *Layer) definition() *Layer {
+	return &Layer{
+		Type:           layer.Type,
+		Inputs:         layer.Inputs,
+		Units:          layer.Units,
+		Activation:     layer.Activation,
+		Loss:           layer.Loss,
+		Rate:           layer.Rate,
+		baseRate:       layer.baseRate,
+		scheduledRate:  layer.scheduledRate,
+		Momentum:       layer.Momentum,
+		L1:             layer.L1,
+		L2:             layer.L2,
+		WeightDecay:    layer.WeightDecay,
+		RegularizeBias: layer.RegularizeBias,
+		Frozen:         layer.Frozen,
+		Dropout:        layer.Dropout,
+		AlphaDropout:   layer.AlphaDropout,
+		Stateful:       layer.Stateful,
+		Channels:       layer.Channels,
+		Height:         layer.Height,
+		Width:          layer.Width,
+		Filters:        layer.Filters,
+		Kernel:         layer.Kernel,
+		Stride:         layer.Stride,
+		Padding:        layer.Padding,
+		Dilation:       layer.Dilation,
+		Sequence:       layer.Sequence,
+		Vocabulary:     layer.Vocabulary,
+		Dimensions:     layer.Dimensions,
+		Heads:          layer.Heads,
+		Hidden:         layer.Hidden,
+		Config:         copyConfig(layer.Config),
+		Random:         layer.Random,
+	}
+}
+
+// copyConfig returns a copy of the config of a kind (nil if there is none)
+func copyConfig(config map[string]float64) map[string]float64 {
+	if config == nil {
+		return nil
+	}
+	copied := make(map[string]float64, len(config))
+	for key, value := range config {
+		copied[key] = value
+	}
+	return copied
+}
+
+// imported restores what the json of a layer doesn't have (neurons link, inputs, units, defaults, etc)
+func (layer *Layer) imported(random *Random) error {
+	// files without type are from dense-only versions
+	if layer.Type == "" {
+		layer.Type = "dense"
+	}
+	layer.instance = newKind(layer.Type)
+	if layer.instance == nil {
+		return fmt.Errorf("unknown layer type %v", layer.Type)
+	}
+
+	if layer.Rate == 0.0 {
+		layer.Rate = 0.001
+	}
+	if layer.Momentum == 0.0 {
+		layer.Momentum = 0.999
+	}
+	if layer.Random == nil {
+		layer.Random = random
+	}
+
+	data := layer.data
+	layer.data = nil
+	if err := layer.kind().Import(layer, data); err != nil {
+		return err
+	}
+	layer.SetActivation(layer.Activation)
+
+	return nil
+}
+
+// plainLayer is a layer without its methods to encode and decode the fields
+type plainLayer Layer
+
+// layerJSON is the json of a layer, Data is what the kind keeps besides the definition and neurons (see Kind.Export)
+type layerJSON struct {
+	*plainLayer
+	Data json.RawMessage `json:"Data,omitempty"`
+}
+
+// MarshalJSON encodes the layer with what its kind exports
+func (layer *Layer) MarshalJSON() ([]byte, error) {
+	encoded := layerJSON{plainLayer: (*plainLayer)(layer)}
+	if kind := layer.kind(); kind != nil {
+		data, err := kind.Export(layer)
+		if err != nil {
+			return nil, err
+		}
+		encoded.Data = data
+	}
+	return json.Marshal(encoded)
+}
+
+// UnmarshalJSON decodes the layer keeping what its kind exported until it's imported
+func (layer *Layer) UnmarshalJSON(encoded []byte) error {
+	decoded := layerJSON{plainLayer: (*plainLayer)(layer)}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		return err
+	}
+	layer.data = decoded.Data
+	return nil
+}
+
 // SetActivation set or change the activation functions based on name
 func (layer *Layer) SetActivation(activation string) ActivationSet {
 	set := selectActivation(activation)
@@ -121,3 +453,13 @@ func (layer *Layer) SetActivation(activation string) ActivationSet {
 	layer.Backward = set.Backward
 	return set
 }
+
+func sign(value float64) float64 {
+	if value > 0.0 {
+		return 1.0
+	}
+	if value < 0.0 {
+		return -1.0
+	}
+	return 0.0
+}
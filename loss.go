@@ -1,4 +1,58 @@
 package neural
 
+import (
+	"math"
+)
+
+// Keeps the outputs of crossentropy away from 0 and 1
+const lossEpsilon = 1e-12
+
 // LossFn is used to calculate the loss
 type LossFn func(output float64, current float64) float64
+
+func meanSquaredError(current []float64, outputs []float64) float64 {
+	loss := 0.0
+	for o := range current {
+		loss += (outputs[o] - current[o]) * (outputs[o] - current[o])
+	}
+	return loss / float64(len(current))
+}
+
+// binaryCrossEntropy is the loss of outputs in (0, 1) like sigmoid, clamped to avoid log(0)
+func binaryCrossEntropy(current []float64, outputs []float64) float64 {
+	loss := 0.0
+	for o := range current {
+		c := math.Max(lossEpsilon, math.Min(1.0-lossEpsilon, current[o]))
+		loss -= outputs[o]*math.Log(c) + (1.0-outputs[o])*math.Log(1.0-c)
+	}
+	return loss / float64(len(current))
+}
+
+// lossErrors returns the loss by name and the errors to backpropagate (negative derivatives of the loss per output)
+// mse errors are target - output (half of the squared error), crossentropy errors are of the sum of every output
+func lossErrors(loss string, current []float64, outputs []float64) (float64, []float64) {
+	errors := make([]float64, len(current))
+
+	switch loss {
+	case "crossentropy":
+		for o := range current {
+			c := math.Max(lossEpsilon, math.Min(1.0-lossEpsilon, current[o]))
+			errors[o] = (outputs[o] - c) / (c * (1.0 - c))
+		}
+		return binaryCrossEntropy(current, outputs), errors
+	}
+
+	for o := range current {
+		errors[o] = outputs[o] - current[o]
+	}
+	return meanSquaredError(current, outputs), errors
+}
+
+// lossObjective is the function whose negative derivatives are the errors of lossErrors
+// (half of the squared error for mse and the sum of every output for crossentropy)
+func lossObjective(loss string, current []float64, outputs []float64) float64 {
+	if loss == "crossentropy" {
+		return binaryCrossEntropy(current, outputs) * float64(len(current))
+	}
+	return meanSquaredError(current, outputs) * float64(len(current)) / 2.0
+}
@@ -0,0 +1,355 @@
+package neural
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+)
+
+// Evaluation is the result of Evaluate, only the requested metrics are calculated
+type Evaluation struct {
+	Metrics []string `json:"Metrics"`
+	Samples int      `json:"Samples"`
+	// Classification (one output is binary with threshold 0.5, several outputs are one-hot classes)
+	Labels    []string  `json:"Labels,omitempty"`
+	Accuracy  float64   `json:"Accuracy"`
+	Precision []float64 `json:"Precision,omitempty"`
+	Recall    []float64 `json:"Recall,omitempty"`
+	F1        []float64 `json:"F1,omitempty"`
+	// Confusion matrix, rows are the actual classes and columns the predicted ones
+	Confusion [][]int `json:"Confusion,omitempty"`
+	LogLoss   float64 `json:"LogLoss,omitempty"`
+	// Area under the ROC curve (macro average of one-vs-rest with several classes),
+	// it's 0 when it's undefined (no class has both positives and negatives)
+	AUC float64 `json:"AUC,omitempty"`
+	// Regression (R2 is the average of all outputs, MAPE skips targets that are zero)
+	R2   float64 `json:"R2"`
+	MAE  float64 `json:"MAE,omitempty"`
+	RMSE float64 `json:"RMSE,omitempty"`
+	MAPE float64 `json:"MAPE,omitempty"`
+}
+
+// ClassificationMetrics and RegressionMetrics are the default metrics of Evaluate
+var (
+	ClassificationMetrics = []string{"accuracy", "precision", "recall", "f1", "confusion", "logloss", "auc"}
+	RegressionMetrics     = []string{"r2", "mae", "rmse", "mape"}
+)
+
+// Evaluate thinks every sample of a dataset (samples with missing values are skipped) and calculates metrics
+// Default metrics are for classification if the neural has a schema with labels, otherwise for regression
+func (neural *Neural) Evaluate(dataset *Dataset, metrics ...string) *Evaluation {
+	if len(metrics) == 0 {
+		metrics = RegressionMetrics
+		if neural.Schema != nil && len(neural.Schema.Labels) > 0 {
+			metrics = ClassificationMetrics
+		}
+	}
+
+	evaluation := &Evaluation{Metrics: metrics}
+	classification, regression := false, false
+
+	for _, metric := range metrics {
+		if contains(ClassificationMetrics, metric) {
+			classification = true
+		} else if contains(RegressionMetrics, metric) {
+			regression = true
+		} else {
+			panic("need a valid metric")
+		}
+	}
+
+	outputs, targets := [][]float64{}, [][]float64{}
+	for _, sample := range dataset.Samples() {
+		if hasMissing(sample[0]) || hasMissing(sample[1]) {
+			continue
+		}
+		outputs = append(outputs, neural.Think(sample[0]))
+		targets = append(targets, sample[1])
+	}
+	evaluation.Samples = len(outputs)
+
+	if evaluation.Samples == 0 {
+		return evaluation
+	}
+
+	if classification {
+		evaluation.classify(neural.Schema, outputs, targets)
+	}
+	if regression {
+		evaluation.regress(outputs, targets)
+	}
+
+	return evaluation
+}
+
+// Has tells if a metric was requested
+func (evaluation *Evaluation) Has(metric string) bool {
+	return contains(evaluation.Metrics, metric)
+}
+
+// String prints the requested metrics as a table
+func (evaluation *Evaluation) String() string {
+	buffer := &bytes.Buffer{}
+	writer := tabwriter.NewWriter(buffer, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(writer, "samples\t%v\n", evaluation.Samples)
+	for _, metric := range []struct {
+		name  string
+		value float64
+	}{
+		{"accuracy", evaluation.Accuracy},
+		{"logloss", evaluation.LogLoss},
+		{"auc", evaluation.AUC},
+		{"r2", evaluation.R2},
+		{"mae", evaluation.MAE},
+		{"rmse", evaluation.RMSE},
+		{"mape", evaluation.MAPE},
+	} {
+		if evaluation.Has(metric.name) {
+			fmt.Fprintf(writer, "%v\t%.4f\n", metric.name, metric.value)
+		}
+	}
+
+	if evaluation.Has("precision") || evaluation.Has("recall") || evaluation.Has("f1") {
+		fmt.Fprintf(writer, "\nclass\tprecision\trecall\tf1\n")
+		for c, label := range evaluation.Labels {
+			fmt.Fprintf(writer, "%v\t%.4f\t%.4f\t%.4f\n", label, evaluation.Precision[c], evaluation.Recall[c], evaluation.F1[c])
+		}
+	}
+
+	if evaluation.Has("confusion") {
+		fmt.Fprintf(writer, "\nactual \\ predicted")
+		for _, label := range evaluation.Labels {
+			fmt.Fprintf(writer, "\t%v", label)
+		}
+		fmt.Fprintf(writer, "\n")
+		for c, label := range evaluation.Labels {
+			fmt.Fprintf(writer, "%v", label)
+			for _, count := range evaluation.Confusion[c] {
+				fmt.Fprintf(writer, "\t%v", count)
+			}
+			fmt.Fprintf(writer, "\n")
+		}
+	}
+
+	writer.Flush()
+	return buffer.String()
+}
+
+// classify calculates the classification metrics
+func (evaluation *Evaluation) classify(schema *Schema, outputs [][]float64, targets [][]float64) {
+	classes := len(outputs[0])
+	if classes == 1 {
+		classes = 2
+	}
+
+	evaluation.Labels = make([]string, classes)
+	for c := range evaluation.Labels {
+		evaluation.Labels[c] = strconv.Itoa(c)
+		if schema != nil && len(schema.Labels) == classes {
+			evaluation.Labels[c] = schema.Labels[c]
+		}
+	}
+
+	evaluation.Confusion = make([][]int, classes)
+	for c := range evaluation.Confusion {
+		evaluation.Confusion[c] = make([]int, classes)
+	}
+
+	// probabilities of every class, one row per sample
+	probabilities := make([][]float64, len(outputs))
+	actual := make([]int, len(outputs))
+
+	for s := range outputs {
+		probabilities[s] = classProbabilities(outputs[s])
+		actual[s] = argmax(classProbabilities(targets[s]))
+		evaluation.Confusion[actual[s]][argmax(probabilities[s])]++
+
+		p := math.Max(math.Min(probabilities[s][actual[s]], 1.0-1e-15), 1e-15)
+		evaluation.LogLoss -= math.Log(p) / float64(len(outputs))
+	}
+
+	correct := 0
+	evaluation.Precision = make([]float64, classes)
+	evaluation.Recall = make([]float64, classes)
+	evaluation.F1 = make([]float64, classes)
+
+	for c := 0; c < classes; c++ {
+		correct += evaluation.Confusion[c][c]
+
+		predicted, real := 0, 0
+		for o := 0; o < classes; o++ {
+			predicted += evaluation.Confusion[o][c]
+			real += evaluation.Confusion[c][o]
+		}
+
+		if predicted > 0 {
+			evaluation.Precision[c] = float64(evaluation.Confusion[c][c]) / float64(predicted)
+		}
+		if real > 0 {
+			evaluation.Recall[c] = float64(evaluation.Confusion[c][c]) / float64(real)
+		}
+		if evaluation.Precision[c]+evaluation.Recall[c] > 0.0 {
+			evaluation.F1[c] = 2.0 * evaluation.Precision[c] * evaluation.Recall[c] / (evaluation.Precision[c] + evaluation.Recall[c])
+		}
+	}
+
+	evaluation.Accuracy = float64(correct) / float64(len(outputs))
+
+	// binary is the AUC of the positive class, otherwise the average of classes with positives and negatives
+	if classes == 2 {
+		if auc := rocAUC(probabilities, actual, 1); !math.IsNaN(auc) {
+			evaluation.AUC = auc
+		}
+		return
+	}
+
+	count := 0
+	for c := 0; c < classes; c++ {
+		if auc := rocAUC(probabilities, actual, c); !math.IsNaN(auc) {
+			evaluation.AUC += auc
+			count++
+		}
+	}
+	if count > 0 {
+		evaluation.AUC /= float64(count)
+	}
+}
+
+// regress calculates the regression metrics
+func (evaluation *Evaluation) regress(outputs [][]float64, targets [][]float64) {
+	values := len(outputs) * len(outputs[0])
+	percentages := 0
+
+	for s := range outputs {
+		for o := range outputs[s] {
+			diff := math.Abs(outputs[s][o] - targets[s][o])
+			evaluation.MAE += diff / float64(values)
+			evaluation.RMSE += diff * diff / float64(values)
+
+			if targets[s][o] != 0.0 {
+				evaluation.MAPE += diff / math.Abs(targets[s][o])
+				percentages++
+			}
+		}
+	}
+
+	evaluation.RMSE = math.Sqrt(evaluation.RMSE)
+	if percentages > 0 {
+		evaluation.MAPE /= float64(percentages)
+	}
+
+	for o := range outputs[0] {
+		mean := 0.0
+		for s := range targets {
+			mean += targets[s][o] / float64(len(targets))
+		}
+
+		residual, total := 0.0, 0.0
+		for s := range targets {
+			residual += (targets[s][o] - outputs[s][o]) * (targets[s][o] - outputs[s][o])
+			total += (targets[s][o] - mean) * (targets[s][o] - mean)
+		}
+
+		r2 := 1.0
+		if total > 0.0 {
+			r2 = 1.0 - residual/total
+		} else if residual > 0.0 {
+			r2 = 0.0
+		}
+		evaluation.R2 += r2 / float64(len(outputs[0]))
+	}
+}
+
+// classProbabilities converts outputs to probabilities that sum one (a single output is the positive class)
+func classProbabilities(outputs []float64) []float64 {
+	if len(outputs) == 1 {
+		p := math.Max(math.Min(outputs[0], 1.0), 0.0)
+		return []float64{1.0 - p, p}
+	}
+
+	probabilities := make([]float64, len(outputs))
+	sum := 0.0
+	for o, output := range outputs {
+		probabilities[o] = math.Max(output, 0.0)
+		sum += probabilities[o]
+	}
+
+	for o := range probabilities {
+		if sum > 0.0 {
+			probabilities[o] /= sum
+		} else {
+			probabilities[o] = 1.0 / float64(len(outputs))
+		}
+	}
+	return probabilities
+}
+
+// rocAUC is the probability that a random positive of the class ranks above a random negative (ties count half)
+func rocAUC(probabilities [][]float64, actual []int, class int) float64 {
+	order := make([]int, len(actual))
+	for s := range order {
+		order[s] = s
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return probabilities[order[a]][class] < probabilities[order[b]][class]
+	})
+
+	positives, negatives := 0, 0
+	ranks := 0.0
+
+	for i := 0; i < len(order); {
+		j := i
+		for j < len(order) && probabilities[order[j]][class] == probabilities[order[i]][class] {
+			j++
+		}
+
+		// tied scores share the average rank
+		rank := float64(i+j+1) / 2.0
+		for k := i; k < j; k++ {
+			if actual[order[k]] == class {
+				positives++
+				ranks += rank
+			} else {
+				negatives++
+			}
+		}
+		i = j
+	}
+
+	if positives == 0 || negatives == 0 {
+		return math.NaN()
+	}
+	return (ranks - float64(positives*(positives+1))/2.0) / float64(positives*negatives)
+}
+
+func argmax(values []float64) int {
+	best := 0
+	for i := range values {
+		if values[i] > values[best] {
+			best = i
+		}
+	}
+	return best
+}
+
+func hasMissing(values []float64) bool {
+	for _, value := range values {
+		if math.IsNaN(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,167 @@
+package neural
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// identity is a linear neural whose outputs are the inputs
+func identity(units int) *Neural {
+	neural := NewNeural([]*Layer{{Inputs: units, Units: units, Activation: "linear"}})
+	for n, neuron := range neural.Layers[0].Neurons {
+		for w := range neuron.Weights {
+			neuron.Weights[w] = 0.0
+		}
+		neuron.Weights[n] = 1.0
+		neuron.Bias = 0.0
+	}
+	return neural
+}
+
+func TestEvaluateAUC(t *testing.T) {
+	tests := []struct {
+		name    string
+		units   int
+		samples [][][]float64
+		auc     float64
+	}{
+		{"binary", 1, [][][]float64{{{0.2}, {0}}, {{0.7}, {1}}, {{0.4}, {0}}, {{0.9}, {1}}}, 1},
+		{"binary of one class", 1, [][][]float64{{{0.2}, {1}}, {{0.7}, {1}}}, 0},
+		{"classes", 3, [][][]float64{{{0.8, 0.1, 0.1}, {1, 0, 0}}, {{0.1, 0.8, 0.1}, {0, 1, 0}}, {{0.1, 0.1, 0.8}, {0, 0, 1}}}, 1},
+		{"classes of one class", 3, [][][]float64{{{0.8, 0.1, 0.1}, {1, 0, 0}}, {{0.6, 0.3, 0.1}, {1, 0, 0}}}, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			evaluation := identity(test.units).Evaluate(NewDataset(test.samples), "auc")
+			if evaluation.AUC != test.auc {
+				t.Fatalf("auc %v, expected %v", evaluation.AUC, test.auc)
+			}
+			if _, err := json.Marshal(evaluation); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// near checks that every value is close to the expected one
+func near(values []float64, expected ...float64) bool {
+	if len(values) != len(expected) {
+		return false
+	}
+	for i := range values {
+		if math.Abs(values[i]-expected[i]) > 1e-12 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestEvaluateClassification(t *testing.T) {
+	tests := []struct {
+		name      string
+		units     int
+		samples   [][][]float64
+		accuracy  float64
+		precision []float64
+		recall    []float64
+		f1        []float64
+		confusion [][]int
+		logloss   float64
+	}{
+		{
+			"binary", 1,
+			[][][]float64{{{0.2}, {0}}, {{0.7}, {1}}, {{0.6}, {0}}, {{0.9}, {1}}, {{0.3}, {1}}},
+			0.6, []float64{0.5, 2.0 / 3.0}, []float64{0.5, 2.0 / 3.0}, []float64{0.5, 2.0 / 3.0},
+			[][]int{{1, 1}, {1, 2}},
+			-(math.Log(0.8) + math.Log(0.7) + math.Log(0.4) + math.Log(0.9) + math.Log(0.3)) / 5.0,
+		},
+		{
+			"no positives", 1,
+			[][][]float64{{{0.2}, {0}}, {{0.7}, {0}}},
+			0.5, []float64{1, 0}, []float64{0.5, 0}, []float64{2.0 / 3.0, 0},
+			[][]int{{1, 1}, {0, 0}},
+			-(math.Log(0.8) + math.Log(0.3)) / 2.0,
+		},
+		{
+			"class never seen", 3,
+			[][][]float64{{{0.8, 0.1, 0.1}, {1, 0, 0}}, {{0.1, 0.8, 0.1}, {0, 1, 0}}, {{0.1, 0.7, 0.2}, {1, 0, 0}}},
+			2.0 / 3.0, []float64{1, 0.5, 0}, []float64{0.5, 1, 0}, []float64{2.0 / 3.0, 2.0 / 3.0, 0},
+			[][]int{{1, 1, 0}, {0, 1, 0}, {0, 0, 0}},
+			-(math.Log(0.8) + math.Log(0.8) + math.Log(0.1)) / 3.0,
+		},
+		{
+			"single class", 1,
+			[][][]float64{{{0.2}, {1}}, {{0.1}, {1}}},
+			0, []float64{0, 0}, []float64{0, 0}, []float64{0, 0},
+			[][]int{{0, 0}, {2, 0}},
+			-(math.Log(0.2) + math.Log(0.1)) / 2.0,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			evaluation := identity(test.units).Evaluate(NewDataset(test.samples), ClassificationMetrics...)
+
+			if evaluation.Samples != len(test.samples) || math.Abs(evaluation.Accuracy-test.accuracy) > 1e-12 {
+				t.Fatalf("accuracy %v of %v samples", evaluation.Accuracy, evaluation.Samples)
+			}
+			if !near(evaluation.Precision, test.precision...) || !near(evaluation.Recall, test.recall...) || !near(evaluation.F1, test.f1...) {
+				t.Fatalf("precision %v recall %v f1 %v", evaluation.Precision, evaluation.Recall, evaluation.F1)
+			}
+			if !reflect.DeepEqual(evaluation.Confusion, test.confusion) {
+				t.Fatalf("confusion %v", evaluation.Confusion)
+			}
+			if math.Abs(evaluation.LogLoss-test.logloss) > 1e-12 {
+				t.Fatalf("logloss %v, expected %v", evaluation.LogLoss, test.logloss)
+			}
+		})
+	}
+}
+
+func TestEvaluateRegression(t *testing.T) {
+	tests := []struct {
+		name    string
+		units   int
+		samples [][][]float64
+		r2      float64
+		mae     float64
+		rmse    float64
+		mape    float64
+	}{
+		{"errors", 1, [][][]float64{{{1}, {2}}, {{3}, {3}}, {{5}, {4}}, {{0}, {0}}}, 1.0 - 2.0/8.75, 0.5, math.Sqrt(0.5), 0.25},
+		{"zero targets", 1, [][][]float64{{{1}, {0}}, {{-1}, {0}}}, 0, 1, 1, 0},
+		{"constant targets", 1, [][][]float64{{{3}, {3}}, {{3}, {3}}}, 1, 0, 0, 0},
+		{"outputs", 2, [][][]float64{{{1, 1}, {1, 2}}, {{2, 2}, {2, 2}}}, 0.5, 0.25, 0.5, 0.125},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			evaluation := identity(test.units).Evaluate(NewDataset(test.samples))
+
+			if !near([]float64{evaluation.R2, evaluation.MAE, evaluation.RMSE, evaluation.MAPE}, test.r2, test.mae, test.rmse, test.mape) {
+				t.Fatalf("r2 %v mae %v rmse %v mape %v", evaluation.R2, evaluation.MAE, evaluation.RMSE, evaluation.MAPE)
+			}
+		})
+	}
+}
+
+func TestEvaluationZeroMetrics(t *testing.T) {
+	// an accuracy or R2 of zero is still reported
+	evaluation := identity(1).Evaluate(NewDataset([][][]float64{{{0.9}, {0}}}), "accuracy", "r2")
+	encoded, err := json.Marshal(evaluation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(encoded), `"Accuracy":0`) || !strings.Contains(string(encoded), `"R2":0`) {
+		t.Fatalf("encoded %s", encoded)
+	}
+
+	// samples with missing values are skipped
+	if evaluation := identity(1).Evaluate(NewDataset([][][]float64{{{math.NaN()}, {0}}, {{1}, {1}}})); evaluation.Samples != 1 {
+		t.Fatalf("%v samples", evaluation.Samples)
+	}
+}
@@ -0,0 +1,282 @@
+package neural
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// maxMigrants is the limit of migrants in a message
+	maxMigrants = 1 << 10
+	// maxMigrantSize is the limit of bytes of an encoded migrant
+	maxMigrantSize = 64 << 20
+)
+
+// Topology decides to which islands the migrants are sent
+type Topology int
+
+const (
+	// Ring sends migrants to the next island only
+	Ring Topology = iota
+	// FullyConnected sends migrants to every other island
+	FullyConnected
+)
+
+// Neighbors returns the islands that receive the migrants of an island
+func (topology Topology) Neighbors(island int, islands int) []int {
+	neighbors := []int{}
+
+	if topology == Ring {
+		if islands > 1 {
+			neighbors = append(neighbors, (island+1)%islands)
+		}
+		return neighbors
+	}
+
+	for i := 0; i < islands; i++ {
+		if i != island {
+			neighbors = append(neighbors, i)
+		}
+	}
+	return neighbors
+}
+
+// Peers returns the addresses of the neighbors of an island (addresses has one per island)
+func (topology Topology) Peers(island int, addresses []string) []string {
+	neighbors := topology.Neighbors(island, len(addresses))
+	peers := make([]string, len(neighbors))
+	for i, neighbor := range neighbors {
+		peers[i] = addresses[neighbor]
+	}
+	return peers
+}
+
+// Migrator moves the best individuals between island populations
+// A migrant is a neural encoded with Export
+type Migrator interface {
+	// Emigrate sends the migrants to the neighbor islands
+	Emigrate(migrants [][]byte) error
+	// Immigrate returns the migrants received since the last call, without blocking
+	Immigrate() ([][]byte, error)
+}
+
+// ChannelMigrator migrates between islands running in the same process
+type ChannelMigrator struct {
+	inbox     chan [][]byte
+	neighbors []*ChannelMigrator
+}
+
+// NewChannelMigrators creates a migrator per island already connected by topology
+func NewChannelMigrators(islands int, topology Topology) []*ChannelMigrator {
+	migrators := make([]*ChannelMigrator, islands)
+	for i := 0; i < islands; i++ {
+		migrators[i] = &ChannelMigrator{inbox: make(chan [][]byte, 64)}
+	}
+
+	for i := 0; i < islands; i++ {
+		for _, neighbor := range topology.Neighbors(i, islands) {
+			migrators[i].neighbors = append(migrators[i].neighbors, migrators[neighbor])
+		}
+	}
+
+	return migrators
+}
+
+// Emigrate sends migrants to the neighbors, they are dropped if an inbox is full
+func (migrator *ChannelMigrator) Emigrate(migrants [][]byte) error {
+	for _, neighbor := range migrator.neighbors {
+		select {
+		case neighbor.inbox <- migrants:
+		default:
+		}
+	}
+	return nil
+}
+
+// Immigrate returns the migrants received so far
+func (migrator *ChannelMigrator) Immigrate() ([][]byte, error) {
+	migrants := [][]byte{}
+	for {
+		select {
+		case received := <-migrator.inbox:
+			migrants = append(migrants, received...)
+		default:
+			return migrants, nil
+		}
+	}
+}
+
+// TCPMigrator migrates between processes, every message is a list of length-prefixed migrants
+type TCPMigrator struct {
+	listener net.Listener
+	peers    []string
+	// Timeout to connect and write to a peer (default is 5 seconds)
+	Timeout  time.Duration
+	mutex    sync.Mutex
+	received [][]byte
+	err      error
+}
+
+// NewTCPMigrator listens on address (like "127.0.0.1:0") and sends migrants to peers
+func NewTCPMigrator(address string, peers []string) (*TCPMigrator, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	migrator := &TCPMigrator{
+		listener: listener,
+		peers:    peers,
+		Timeout:  5 * time.Second,
+	}
+
+	go migrator.accept()
+
+	return migrator, nil
+}
+
+// Addr is the address where migrants are received
+func (migrator *TCPMigrator) Addr() string {
+	return migrator.listener.Addr().String()
+}
+
+// SetPeers changes the addresses where migrants are sent (useful with port 0 listeners)
+func (migrator *TCPMigrator) SetPeers(peers []string) {
+	migrator.mutex.Lock()
+	migrator.peers = peers
+	migrator.mutex.Unlock()
+}
+
+// Close stops receiving migrants
+func (migrator *TCPMigrator) Close() error {
+	return migrator.listener.Close()
+}
+
+// PeerError is a peer that didn't receive the migrants
+type PeerError struct {
+	Peer string
+	Err  error
+}
+
+func (err *PeerError) Error() string {
+	return fmt.Sprintf("migrants not sent to %v: %v", err.Peer, err.Err)
+}
+
+// Emigrate sends migrants to every peer, the first unreachable or failed peer is returned as a PeerError
+// after trying the rest of the peers
+func (migrator *TCPMigrator) Emigrate(migrants [][]byte) error {
+	migrator.mutex.Lock()
+	peers := migrator.peers
+	migrator.mutex.Unlock()
+
+	var failed error
+	for _, peer := range peers {
+		if err := migrator.send(peer, migrants); err != nil && failed == nil {
+			failed = &PeerError{Peer: peer, Err: err}
+		}
+	}
+
+	return failed
+}
+
+func (migrator *TCPMigrator) send(peer string, migrants [][]byte) error {
+	conn, err := net.DialTimeout("tcp", peer, migrator.Timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	conn.SetWriteDeadline(time.Now().Add(migrator.Timeout))
+	return writeMigrants(conn, migrants)
+}
+
+// Immigrate returns the migrants received so far
+func (migrator *TCPMigrator) Immigrate() ([][]byte, error) {
+	migrator.mutex.Lock()
+	defer migrator.mutex.Unlock()
+
+	migrants, err := migrator.received, migrator.err
+	migrator.received, migrator.err = nil, nil
+	return migrants, err
+}
+
+func (migrator *TCPMigrator) accept() {
+	for {
+		conn, err := migrator.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go func() {
+			defer conn.Close()
+			conn.SetReadDeadline(time.Now().Add(migrator.Timeout))
+
+			migrants, err := readMigrants(conn)
+
+			migrator.mutex.Lock()
+			if err != nil {
+				migrator.err = err
+			} else {
+				migrator.received = append(migrator.received, migrants...)
+			}
+			migrator.mutex.Unlock()
+		}()
+	}
+}
+
+func writeMigrants(w io.Writer, migrants [][]byte) error {
+	if err := binary.Write(w, binary.BigEndian, uint32(len(migrants))); err != nil {
+		return err
+	}
+
+	for _, migrant := range migrants {
+		if err := binary.Write(w, binary.BigEndian, uint32(len(migrant))); err != nil {
+			return err
+		}
+		if _, err := w.Write(migrant); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readMigrants reads a message of writeMigrants, the sizes are checked and the memory grows as data arrives
+// so a wrong or malicious header can't allocate more than what was really sent
+func readMigrants(r io.Reader) ([][]byte, error) {
+	var total uint32
+	if err := binary.Read(r, binary.BigEndian, &total); err != nil {
+		return nil, err
+	}
+	if total > maxMigrants {
+		return nil, errors.New("too many migrants")
+	}
+
+	migrants := [][]byte{}
+	for i := uint32(0); i < total; i++ {
+		var size uint32
+		if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+			return nil, err
+		}
+		if size > maxMigrantSize {
+			return nil, errors.New("migrant too big")
+		}
+
+		migrant := bytes.Buffer{}
+		if _, err := io.CopyN(&migrant, r, int64(size)); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		migrants = append(migrants, migrant.Bytes())
+	}
+
+	return migrants, nil
+}
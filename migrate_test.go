@@ -0,0 +1,216 @@
+package neural
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+func TestChannelMigrator(t *testing.T) {
+	migrators := NewChannelMigrators(3, Ring)
+
+	if err := migrators[0].Emigrate([][]byte{[]byte("a"), []byte("b")}); err != nil {
+		t.Fatal(err)
+	}
+
+	received, err := migrators[1].Immigrate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 2 || string(received[0]) != "a" || string(received[1]) != "b" {
+		t.Fatalf("received %q", received)
+	}
+
+	// the ring only sends to the next island and the inbox is empty after reading it
+	for _, migrator := range []*ChannelMigrator{migrators[0], migrators[1], migrators[2]} {
+		if received, _ := migrator.Immigrate(); len(received) != 0 {
+			t.Fatalf("unexpected migrants %q", received)
+		}
+	}
+}
+
+func TestTCPMigrator(t *testing.T) {
+	a, err := NewTCPMigrator("127.0.0.1:0", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	b, err := NewTCPMigrator("127.0.0.1:0", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	a.SetPeers([]string{b.Addr()})
+
+	migrant, err := NewNeural([]*Layer{{Inputs: 2, Units: 1}}).Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Emigrate([][]byte{migrant, []byte{}}); err != nil {
+		t.Fatal(err)
+	}
+
+	received := [][]byte{}
+	for deadline := time.Now().Add(5 * time.Second); len(received) < 2 && time.Now().Before(deadline); {
+		immigrants, err := b.Immigrate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		received = append(received, immigrants...)
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if len(received) != 2 || !bytes.Equal(received[0], migrant) || len(received[1]) != 0 {
+		t.Fatalf("received %v migrants", len(received))
+	}
+	if err := NewNeural([]*Layer{{Inputs: 2, Units: 1}}).Import(received[0]); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadMigrantsLimits(t *testing.T) {
+	header := func(values ...uint32) []byte {
+		buffer := bytes.Buffer{}
+		for _, value := range values {
+			binary.Write(&buffer, binary.BigEndian, value)
+		}
+		return buffer.Bytes()
+	}
+
+	tests := []struct {
+		name    string
+		message []byte
+	}{
+		{"too many migrants", header(maxMigrants + 1)},
+		{"migrant too big", header(1, maxMigrantSize+1)},
+		{"huge size without data", append(header(1, maxMigrantSize), 1, 2, 3)},
+		{"less migrants than the total", append(header(2, 1), 'a')},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := readMigrants(bytes.NewReader(test.message)); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+
+	buffer := bytes.Buffer{}
+	if err := writeMigrants(&buffer, [][]byte{[]byte("abc")}); err != nil {
+		t.Fatal(err)
+	}
+	migrants, err := readMigrants(&buffer)
+	if err != nil || len(migrants) != 1 || string(migrants[0]) != "abc" {
+		t.Fatalf("read %q %v", migrants, err)
+	}
+}
+
+func TestTCPMigratorUnreachablePeer(t *testing.T) {
+	a, err := NewTCPMigrator("127.0.0.1:0", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	b, err := NewTCPMigrator("127.0.0.1:0", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	closed, err := NewTCPMigrator("127.0.0.1:0", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed.Close()
+
+	// the reachable peer still receives the migrants
+	a.SetPeers([]string{closed.Addr(), b.Addr()})
+	err = a.Emigrate([][]byte{[]byte("a")})
+	if failed, ok := err.(*PeerError); !ok || failed.Peer != closed.Addr() {
+		t.Fatalf("expected a peer error of %v, got %v", closed.Addr(), err)
+	}
+
+	received := [][]byte{}
+	for deadline := time.Now().Add(5 * time.Second); len(received) < 1 && time.Now().Before(deadline); {
+		immigrants, _ := b.Immigrate()
+		received = append(received, immigrants...)
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(received) != 1 {
+		t.Fatalf("received %v migrants", len(received))
+	}
+}
+
+func TestEvolutionMigrantsOverElitism(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic")
+		}
+	}()
+
+	neural := NewNeural([]*Layer{{Inputs: 2, Units: 1}})
+	neural.Evolution(Evolve{
+		Population: 6,
+		Elitism:    1,
+		Migrants:   3,
+		Epochs:     1,
+		Dataset:    [][][]float64{{{0, 1}, {1}}},
+		Migrator:   NewChannelMigrators(2, Ring)[0],
+	})
+}
+
+func TestImmigrantsKeepEliteAndChildren(t *testing.T) {
+	migrators := NewChannelMigrators(2, Ring)
+	neural := NewNeural([]*Layer{{Inputs: 2, Units: 1}})
+
+	population := make([]*Neural, 6)
+	for p := range population {
+		population[p] = neural.Clone()
+	}
+	original := append([]*Neural{}, population...)
+
+	immigrants := make([][]byte, 5)
+	for i := range immigrants {
+		immigrants[i], _ = neural.Export()
+	}
+	migrators[0].Emigrate(immigrants)
+
+	// the elite is 0 and 1 and the children of the crossover are 4 and 5, so only two immigrants fit
+	if err := neural.immigrate(population, Evolve{Elitism: 2, Migrator: migrators[1]}); err != nil {
+		t.Fatal(err)
+	}
+	for p, replaced := range []bool{false, false, true, true, false, false} {
+		if (population[p] != original[p]) != replaced {
+			t.Fatalf("individual %v replaced %v", p, !replaced)
+		}
+	}
+}
+
+func TestEvolutionMigratesElite(t *testing.T) {
+	migrators := NewChannelMigrators(2, Ring)
+	neural := NewNeural([]*Layer{{Inputs: 2, Units: 2}, {Units: 1}})
+	neural.Seed(1)
+
+	_, err := neural.Evolution(Evolve{
+		Population:        6,
+		Elitism:           2,
+		Migrants:          2,
+		Epochs:            2,
+		Dataset:           [][][]float64{{{0, 1}, {1}}},
+		Migrator:          migrators[0],
+		MigrationInterval: 1,
+		Callback:          func(epoch int, loss float64) bool { return true },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received, _ := migrators[1].Immigrate()
+	if len(received) != 2 {
+		t.Fatalf("received %v migrants", len(received))
+	}
+}
@@ -3,7 +3,9 @@ package neural
 import (
 	"encoding/json"
 	"io/ioutil"
+	"math"
 	"os"
+	"path/filepath"
 	"sort"
 )
 
@@ -13,6 +15,37 @@ type Neural struct {
 	Layers    []*Layer `json:"Layers"`
 	// Average of loss (used in Learns, LearnsRaw and Evolve)
 	Loss float64 `json:"-"`
+	// Regularization term (L1 and L2 of all layers) after Learns or LearnsRaw, not included in Loss
+	Penalty float64 `json:"-"`
+	// Source of randomness shared by all layers (default is crypto/rand)
+	Random *Random `json:"-"`
+	// Clip every gradient to [-ClipValue, ClipValue] (default is no clipping)
+	ClipValue float64 `json:"-"`
+	// Scale the gradients of all layers when their global norm is bigger (default is no clipping)
+	ClipNorm float64 `json:"-"`
+	// Changes the rate of all layers while learning (default is constant rate)
+	Scheduler Scheduler `json:"-"`
+	// Dataset to calculate the loss given to the scheduler after every epoch (default is the training loss)
+	Validation [][][]float64 `json:"-"`
+	// Samples learned together by Learns and LearnsRaw, one update with the average of their gradients (default is 1)
+	Batch int `json:"-"`
+	// Goroutines computing the gradients of a batch, the result is the same with any amount (default is 1)
+	Workers int `json:"-"`
+	// Steps of a sequence backpropagated at once by LearnSequence (default is the whole sequence)
+	Truncate int `json:"-"`
+	// Columns of the inputs and labels of the outputs, used by Predict (exported with the neural)
+	Schema *Schema `json:"Schema,omitempty"`
+	// Error that aborted the training (like weights that are not finite anymore)
+	err error
+	// Forward and the gradient checks use inference mode after Eval (default is training mode)
+	eval bool
+	// Outputs of the last Forward
+	current []float64
+	// Copies of the neural used by the workers
+	replicas []*Neural
+	// Amount of learned samples and epochs
+	steps  int
+	epochs int
 }
 
 // Evolve is the config for evolution process
@@ -25,7 +58,22 @@ type Evolve struct {
 	Iterations int
 	Threshold  float64
 	Dataset    [][][]float64
-	Callback   func(epoch int, loss float64) bool
+	// Dataset with named columns (used if Dataset is empty)
+	Data     *Dataset
+	Callback func(epoch int, loss float64) bool
+	// Directory where the population is saved to resume the run later
+	Checkpoint string
+	// Save the checkpoint every N epochs (default is 1)
+	CheckpointEvery int
+	// Continue from the checkpoint in the directory (if there is one)
+	Resume bool
+	// Exchange the best individuals with other island populations
+	Migrator Migrator
+	// Migrate every N epochs (default is 10)
+	MigrationInterval int
+	// Amount of best individuals sent in every migration, taken from the elite (default is 1, at most Elitism)
+	// Immigrants replace the worst individuals that are not elite or children of the last crossover
+	Migrants int
 }
 
 // NewNeural creates a neural based on multiple layers
@@ -36,6 +84,13 @@ func NewNeural(layers []*Layer) *Neural {
 	}
 
 	for i, prevUnits := 0, 0; i < neural.MaxLayers; i++ {
+		if i > 0 {
+			layers[i].inheritShape(neural.Layers[i-1])
+		}
+		if layers[i].Inputs == 0 && layers[i].Channels > 0 {
+			layers[i].Inputs = layers[i].shapeInputs()
+		}
+
 		if layers[i].Inputs == 0 {
 			if prevUnits == 0 {
 				panic("need the first layer with defined inputs")
@@ -44,19 +99,35 @@ func NewNeural(layers []*Layer) *Neural {
 			layers[i].Inputs = prevUnits
 		}
 
-		prevUnits = layers[i].Units
+		if layers[i].Units == 0 && layers[i].isNorm() {
+			layers[i].Units = layers[i].Inputs
+		}
+
 		neural.Layers[i] = NewLayer(layers[i])
+		prevUnits = neural.Layers[i].Units
+	}
+
+	if neural.MaxLayers > 0 && neural.Layers[neural.MaxLayers-1].Dropout > 0.0 {
+		panic("need dropout only in hidden layers")
 	}
 
 	return neural
 }
 
 // ThinkRaw process the neural forward based on inputs and then based on output of previous layer
+// It overwrites what Backward needs of the last Forward, so a Backward after it panics
 func (neural *Neural) ThinkRaw(inputs []float64) []float64 {
-	outs := neural.Layers[0].Think(inputs)
+	neural.current = nil
+	return neural.forward(inputs, false)
+}
+
+// forward process the neural in training (LearnRaw) or inference (ThinkRaw) mode
+// Training mode applies random dropout masks, inference mode uses scaled outputs
+func (neural *Neural) forward(inputs []float64, training bool) []float64 {
+	outs := neural.Layers[0].forward(inputs, training)
 
 	for i := 1; i < neural.MaxLayers; i++ {
-		outs = neural.Layers[i].Think(outs)
+		outs = neural.Layers[i].forward(outs, training)
 	}
 
 	return outs
@@ -67,48 +138,172 @@ func (neural *Neural) Think(inputs []float64) []float64 {
 	return neural.OutputValuesFromRaw(neural.ThinkRaw(neural.InputValuesToRaw(inputs)))
 }
 
-// LearnRaw uses backpropagation
+// LearnRaw uses backpropagation (ZeroGrad, Forward, Backward and Step)
+// It returns NaN without learning after training was aborted (check Err)
 func (neural *Neural) LearnRaw(inputs []float64, outputs []float64) float64 {
-	loss := 0.0
-	outputLayer := neural.Layers[neural.MaxLayers-1]
-	currentOut := neural.ThinkRaw(inputs)
+	if neural.err != nil {
+		return math.NaN()
+	}
+
+	neural.ZeroGrad()
+	neural.keepForward(inputs, true)
+	loss := neural.Backward(outputs)
 
-	for o, output := range outputLayer.Neurons {
-		output.error = outputs[o] - currentOut[o]
-		output.delta = output.Layer.Backward(output.activation) * output.error
-		loss += output.error * output.error
+	if err := neural.Step(); err != nil {
+		return math.NaN()
 	}
 
-	for l := neural.MaxLayers - 2; l >= 0; l-- {
-		layer := neural.Layers[l]
-		nextLayer := neural.Layers[l+1]
+	return loss
+}
 
-		for h, hidden := range layer.Neurons {
-			hidden.error = 0.0
-			for _, next := range nextLayer.Neurons {
-				hidden.error += next.Weights[h] * next.delta
-			}
-			hidden.delta = hidden.Layer.Backward(hidden.activation) * hidden.error
+// Forward thinks raw inputs in the mode of the neural (training unless Eval) keeping what the next Backward needs
+func (neural *Neural) Forward(inputs []float64) []float64 {
+	return neural.keepForward(inputs, neural.Training())
+}
+
+// keepForward thinks raw inputs in a mode keeping what the next Backward needs
+func (neural *Neural) keepForward(inputs []float64, training bool) []float64 {
+	neural.current = neural.forward(inputs, training)
+	return neural.current
+}
+
+// Train sets training mode (the default): Forward and the gradient checks use random dropout masks
+// and batchnorm updates its running statistics. LearnRaw always trains and ThinkRaw always infers
+func (neural *Neural) Train() {
+	neural.eval = false
+}
+
+// Eval sets inference mode: Forward and the gradient checks use scaled dropout and the batchnorm running statistics
+func (neural *Neural) Eval() {
+	neural.eval = true
+}
+
+// Training is true in training mode
+func (neural *Neural) Training() bool {
+	return !neural.eval
+}
+
+// Backward adds the gradients of the raw outputs expected for the last Forward and returns the loss (mse)
+// Several Forward and Backward accumulate their gradients, like a batch or different losses
+// Thinking in between overwrites the inputs and activations of the layers, Backward panics instead of using them
+func (neural *Neural) Backward(outputs []float64) float64 {
+	if neural.current == nil {
+		panic("need a forward before backward (without thinking in between)")
+	}
+	return neural.backward(neural.current, outputs)
+}
+
+// Step updates the weights of all layers with the accumulated gradients (clipped if enabled), it doesn't zero them
+// It returns the error that aborted the training (same as Err)
+func (neural *Neural) Step() error {
+	if neural.err != nil {
+		return neural.err
+	}
+
+	if neural.Scheduler != nil {
+		neural.schedule(neural.Scheduler.Step(neural.steps))
+	}
+	neural.steps++
+
+	if err := neural.update(); err != nil {
+		neural.err = err
+	}
+
+	return neural.err
+}
+
+// ZeroGrad clears the accumulated gradients of all layers
+func (neural *Neural) ZeroGrad() {
+	for i := 0; i < neural.MaxLayers; i++ {
+		neural.Layers[i].zeroGrad()
+	}
+}
+
+// update weights of all layers with the gradients (clipped if enabled)
+// Every new param is computed first, nothing changes if any of them wouldn't be finite
+func (neural *Neural) update() error {
+	neural.clip()
+
+	updates := []paramUpdate{}
+	for i := 0; i < neural.MaxLayers; i++ {
+		layerUpdates, err := neural.Layers[i].updates()
+		if err != nil {
+			err.Layer = i
+			return err
 		}
+		updates = append(updates, layerUpdates...)
+	}
+
+	for _, update := range updates {
+		update.apply()
+	}
+
+	return nil
+}
+
+// Err is the error that aborted the training, Reset or ClearErr allows to learn again
+func (neural *Neural) Err() error {
+	return neural.err
+}
+
+// ClearErr allows to learn again after training was aborted
+func (neural *Neural) ClearErr() {
+	neural.err = nil
+}
+
+// backward propagates the error of the current outputs through all layers to add their gradients
+// It doesn't change weights and returns the loss (mse)
+func (neural *Neural) backward(current []float64, outputs []float64) float64 {
+	loss, errors := lossErrors("mse", current, outputs)
+
+	for l := neural.MaxLayers - 1; l >= 0; l-- {
+		errors = neural.Layers[l].backward(errors)
+	}
+
+	return loss
+}
+
+// endEpoch gives the loss of the epoch to the scheduler (validation loss if there is a validation dataset)
+func (neural *Neural) endEpoch(raw bool) {
+	neural.epochs++
 
-		for _, next := range nextLayer.Neurons {
-			for w := 0; w < next.MaxInputs; w++ {
-				next.Weights[w] += next.Optimizer(w, next.Inputs[w]*next.delta*next.Layer.Rate)
+	if neural.Scheduler == nil {
+		return
+	}
+
+	loss := neural.Loss
+	if len(neural.Validation) > 0 {
+		loss = 0.0
+		for _, data := range neural.Validation {
+			inputs, outputs := data[0], data[1]
+			if !raw {
+				inputs, outputs = neural.InputValuesToRaw(inputs), neural.OutputValuesToRaw(outputs)
 			}
-			next.Bias += next.Optimizer(next.MaxInputs, next.delta*next.Layer.Rate)
+			loss += meanSquaredError(neural.ThinkRaw(inputs), outputs)
 		}
+		loss /= float64(len(neural.Validation))
 	}
 
-	return loss / float64(outputLayer.Units)
+	neural.schedule(neural.Scheduler.Epoch(neural.epochs, loss))
 }
 
 // LearnsRaw is a shorcut to learn a raw dataset of inputs/outputs backed by LearnRaw method
+// With Batch the samples are learned in mini-batches (using Workers)
 func (neural *Neural) LearnsRaw(dataset [][][]float64) float64 {
 	neural.Loss = 0.0
-	for _, data := range dataset {
-		neural.Loss += neural.LearnRaw(data[0], data[1])
+	if neural.Batch > 1 {
+		neural.Loss = neural.learnBatches(dataset)
+	} else {
+		for _, data := range dataset {
+			neural.Loss += neural.LearnRaw(data[0], data[1])
+			if neural.err != nil {
+				break
+			}
+		}
+		neural.Loss /= float64(len(dataset))
 	}
-	neural.Loss /= float64(len(dataset))
+	neural.Penalty = neural.penalty()
+	neural.endEpoch(true)
 	return neural.Loss
 }
 
@@ -118,33 +313,58 @@ func (neural *Neural) Learn(inputs []float64, outputs []float64) float64 {
 }
 
 // Learns is a shorcut to learn dataset of arbitrary inputs/outputs backed by Learn method
+// With Batch the samples are learned in mini-batches (using Workers)
 func (neural *Neural) Learns(dataset [][][]float64) float64 {
 	neural.Loss = 0.0
-	for _, data := range dataset {
-		neural.Loss += neural.Learn(data[0], data[1])
+	if neural.Batch > 1 {
+		raw := make([][][]float64, len(dataset))
+		for i, data := range dataset {
+			raw[i] = [][]float64{neural.InputValuesToRaw(data[0]), neural.OutputValuesToRaw(data[1])}
+		}
+		neural.Loss = neural.learnBatches(raw)
+	} else {
+		for _, data := range dataset {
+			neural.Loss += neural.Learn(data[0], data[1])
+			if neural.err != nil {
+				break
+			}
+		}
+		neural.Loss /= float64(len(dataset))
 	}
-	neural.Loss /= float64(len(dataset))
+	neural.Penalty = neural.penalty()
+	neural.endEpoch(false)
 	return neural.Loss
 }
 
+// penalty is the regularization term of all layers
+func (neural *Neural) penalty() float64 {
+	penalty := 0.0
+	for i := 0; i < neural.MaxLayers; i++ {
+		penalty += neural.Layers[i].penalty()
+	}
+	return penalty
+}
+
 // Clone neural with same layers
 func (neural *Neural) Clone() *Neural {
 	layers := make([]*Layer, neural.MaxLayers)
 
 	for i := 0; i < neural.MaxLayers; i++ {
-		layers[i] = &Layer{
-			Inputs:     neural.Layers[i].Inputs,
-			Units:      neural.Layers[i].Units,
-			Activation: neural.Layers[i].Activation,
-			Rate:       neural.Layers[i].Rate,
-			Momentum:   neural.Layers[i].Momentum,
-		}
-
+		layers[i] = neural.Layers[i].definition()
 		layers[i].Range = make([][]float64, len(neural.Layers[i].Range))
 		copy(layers[i].Range, neural.Layers[i].Range)
 	}
 
 	clone := NewNeural(layers)
+	clone.Random = neural.Random
+	clone.ClipValue = neural.ClipValue
+	clone.ClipNorm = neural.ClipNorm
+	clone.Batch = neural.Batch
+	clone.Workers = neural.Workers
+	clone.Truncate = neural.Truncate
+	clone.Schema = neural.Schema
+	clone.Scheduler = cloneScheduler(neural.Scheduler)
+	clone.eval = neural.eval
 
 	for i := 0; i < neural.MaxLayers; i++ {
 		clone.Layers[i] = neural.Layers[i].Clone()
@@ -164,6 +384,15 @@ func (neural *Neural) Mutate(probability float64) {
 func (neural *Neural) Crossover(neuralB *Neural, dominant float64) *Neural {
 	new := NewNeural([]*Layer{})
 	new.MaxLayers = neural.MaxLayers
+	new.Random = neural.Random
+	new.ClipValue = neural.ClipValue
+	new.ClipNorm = neural.ClipNorm
+	new.Batch = neural.Batch
+	new.Workers = neural.Workers
+	new.Truncate = neural.Truncate
+	new.Schema = neural.Schema
+	new.Scheduler = cloneScheduler(neural.Scheduler)
+	new.eval = neural.eval
 	new.Layers = make([]*Layer, neural.MaxLayers)
 
 	for i := 0; i < neural.MaxLayers; i++ {
@@ -174,7 +403,17 @@ func (neural *Neural) Crossover(neuralB *Neural, dominant float64) *Neural {
 }
 
 // Evolve uses Clone, Mutate, Learns and Crossover to create a evolutionary scenario
+// It panics on checkpoint and migration errors, use Evolution to get them instead
 func (neural *Neural) Evolve(evolve Evolve) *Neural {
+	best, err := neural.Evolution(evolve)
+	if err != nil {
+		panic(err)
+	}
+	return best
+}
+
+// Evolution is Evolve returning the checkpoint and migration errors
+func (neural *Neural) Evolution(evolve Evolve) (*Neural, error) {
 	if evolve.Population == 0 {
 		evolve.Population = 20
 	}
@@ -194,47 +433,157 @@ func (neural *Neural) Evolve(evolve Evolve) *Neural {
 		evolve.Iterations = 1
 	}
 
+	if len(evolve.Dataset) == 0 && evolve.Data != nil {
+		evolve.Dataset = evolve.Data.Samples()
+	}
+	if evolve.CheckpointEvery == 0 {
+		evolve.CheckpointEvery = 1
+	}
+	if evolve.MigrationInterval == 0 {
+		evolve.MigrationInterval = 10
+	}
+	if evolve.Migrants == 0 {
+		evolve.Migrants = 1
+	}
+	if evolve.Migrator != nil && evolve.Migrants > evolve.Elitism {
+		panic("need migrants to be less or equal than elitism")
+	}
+
+	state := &Checkpoint{}
 	population := make([]*Neural, evolve.Population)
 	for p := 0; p < evolve.Population; p++ {
 		population[p] = neural.Clone()
 	}
 
-	for e := 0; e < evolve.Epochs; e++ {
+	filename := filepath.Join(evolve.Checkpoint, checkpointFile)
+	if evolve.Checkpoint != "" {
+		if err := os.MkdirAll(evolve.Checkpoint, 0755); err != nil {
+			return nil, err
+		}
+
+		if evolve.Resume && fileExists(filename) {
+			if err := state.FromFile(filename); err != nil {
+				return nil, err
+			}
+
+			resumed, err := state.Neurals(neural)
+			if err != nil {
+				return nil, err
+			}
+
+			population = resumed
+			evolve.Population = len(population)
+		}
+	}
+
+	// save the population as the state before the epoch
+	save := func(epoch int) error {
+		if err := state.Update(population, epoch); err != nil {
+			return err
+		}
+		return state.ToFile(filename)
+	}
+
+	for e := state.Epoch; e < evolve.Epochs; e++ {
+		mean := 0.0
+
 		for p := 0; p < evolve.Population; p++ {
+			population[p].ClearErr()
 			population[p].Mutate(evolve.Mutate)
 
 			for i := 0; i < evolve.Iterations; i++ {
 				population[p].Learns(evolve.Dataset)
 			}
+
+			mean += population[p].Loss
 		}
 
 		sort.Slice(population, func(a int, b int) bool {
-			return population[a].Loss < population[b].Loss
+			return population[a].Loss < population[b].Loss || (math.IsNaN(population[b].Loss) && !math.IsNaN(population[a].Loss))
 		})
 
-		if evolve.Callback(e, population[0].Loss) == false {
-			break
-		}
+		state.Best = append(state.Best, population[0].Loss)
+		state.Mean = append(state.Mean, mean/float64(evolve.Population))
 
-		if population[0].Loss <= evolve.Threshold {
+		// the last epoch (limit, threshold or callback) always saves the sorted population
+		if evolve.Callback(e, population[0].Loss) == false || population[0].Loss <= evolve.Threshold || e == evolve.Epochs-1 {
+			if evolve.Checkpoint != "" {
+				if err := save(e + 1); err != nil {
+					return nil, err
+				}
+			}
 			break
 		}
 
-		if e == evolve.Epochs-1 {
-			break
+		// migrants are sent before the crossover replaces any individual of the sorted population
+		migrating := evolve.Migrator != nil && (e+1)%evolve.MigrationInterval == 0
+		if migrating {
+			if err := neural.emigrate(population, evolve); err != nil {
+				return nil, err
+			}
 		}
 
 		for p := 0; p < evolve.Population; p++ {
 			if p < evolve.Elitism {
-				randomIndex := randomInt(int64(evolve.Population))
+				randomIndex := neural.Random.Intn(evolve.Population)
 				children := population[p].Crossover(population[randomIndex], evolve.Crossover)
 
 				population[evolve.Population-1-p] = children
 			}
 		}
+
+		if migrating {
+			if err := neural.immigrate(population, evolve); err != nil {
+				return nil, err
+			}
+		}
+
+		if evolve.Checkpoint != "" && (e+1)%evolve.CheckpointEvery == 0 {
+			if err := save(e + 1); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return population[0], nil
+}
+
+// emigrate sends the best individuals of the elite (population must be sorted)
+func (neural *Neural) emigrate(population []*Neural, evolve Evolve) error {
+	migrants := make([][]byte, 0, evolve.Migrants)
+	for p := 0; p < evolve.Migrants && p < len(population); p++ {
+		encoded, err := population[p].Export()
+		if err != nil {
+			return err
+		}
+		migrants = append(migrants, encoded)
+	}
+
+	return evolve.Migrator.Emigrate(migrants)
+}
+
+// immigrate replaces the worst individuals with the received ones, the elite and the children of the crossover
+// (the last Elitism individuals) are kept so the immigrants that don't fit are dropped
+func (neural *Neural) immigrate(population []*Neural, evolve Evolve) error {
+	immigrants, err := evolve.Migrator.Immigrate()
+	if err != nil {
+		return err
+	}
+
+	for i, encoded := range immigrants {
+		p := len(population) - 1 - evolve.Elitism - i
+		if p < evolve.Elitism {
+			break
+		}
+
+		immigrant := neural.Clone()
+		if err := immigrant.Import(encoded); err != nil {
+			return err
+		}
+		population[p] = immigrant
 	}
 
-	return population[0]
+	return nil
 }
 
 // Reset neurons (weights, bias, etc) of all layers
@@ -242,6 +591,30 @@ func (neural *Neural) Reset() {
 	for i := 0; i < neural.MaxLayers; i++ {
 		neural.Layers[i].Reset()
 	}
+	neural.err = nil
+}
+
+// Freeze layers in the range [from, to) so learning and genetics don't change them (like pretrained features)
+func (neural *Neural) Freeze(from int, to int) {
+	for i := from; i < to; i++ {
+		neural.Layers[i].Frozen = true
+	}
+}
+
+// Unfreeze layers in the range [from, to) so they are trainable again
+func (neural *Neural) Unfreeze(from int, to int) {
+	for i := from; i < to; i++ {
+		neural.Layers[i].Frozen = false
+	}
+}
+
+// Seed sets a reproducible source of randomness for all layers (use Reset to also randomize weights with it)
+func (neural *Neural) Seed(seed int64) {
+	neural.Random = NewRandom(seed)
+
+	for i := 0; i < neural.MaxLayers; i++ {
+		neural.Layers[i].Random = neural.Random
+	}
 }
 
 // Rate set the rate for all layers
@@ -265,19 +638,15 @@ func (neural *Neural) Export() ([]byte, error) {
 
 // Import neural from json string
 func (neural *Neural) Import(encoded []byte) error {
-	json.Unmarshal(encoded, &neural)
+	if err := json.Unmarshal(encoded, &neural); err != nil {
+		return err
+	}
 
 	neural.MaxLayers = len(neural.Layers)
 
 	for _, layer := range neural.Layers {
-		layer.Inputs = len(layer.Neurons[0].Weights)
-		layer.Units = len(layer.Neurons)
-		layer.SetActivation(layer.Activation)
-
-		for _, neuron := range layer.Neurons {
-			neuron.MaxInputs = len(neuron.Weights)
-			neuron.Layer = layer
-			neuron.Inputs = make([]float64, neuron.MaxInputs)
+		if err := layer.imported(neural.Random); err != nil {
+			return err
 		}
 	}
 
@@ -307,49 +676,19 @@ func (neural *Neural) DeleteFile(filename string) error {
 	return os.Remove(filename)
 }
 
-// InputValuesToRaw converts arbitrary input values to raw (using layer range property)
+// InputValuesToRaw converts arbitrary input values to raw (using layer scalers or range property)
 func (neural *Neural) InputValuesToRaw(inputs []float64) []float64 {
-	layer := neural.Layers[0]
-	total := len(layer.Range)
-	if total == 0 {
-		return inputs
-	}
-
-	raw := make([]float64, total)
-	for i, ranges := range layer.Range {
-		raw[i] = rangeToRange(inputs[i], ranges[0], ranges[1], ranges[2], ranges[3])
-	}
-	return raw
+	return neural.inputRanges().toRaw(inputs)
 }
 
-// OutputValuesToRaw converts arbitrary output values to raw (using layer range property)
+// OutputValuesToRaw converts arbitrary output values to raw (using layer scalers or range property)
 func (neural *Neural) OutputValuesToRaw(outputs []float64) []float64 {
-	layer := neural.Layers[neural.MaxLayers-1]
-	total := len(layer.Range)
-	if total == 0 {
-		return outputs
-	}
-
-	raw := make([]float64, total)
-	for i, ranges := range layer.Range {
-		raw[i] = rangeToRange(outputs[i], ranges[0], ranges[1], ranges[2], ranges[3])
-	}
-	return raw
+	return neural.outputRanges().toRaw(outputs)
 }
 
-// OutputValuesFromRaw converts raw output to arbitrary output values (using layer range property)
+// OutputValuesFromRaw converts raw output to arbitrary output values (using layer scalers or range property)
 func (neural *Neural) OutputValuesFromRaw(outputs []float64) []float64 {
-	layer := neural.Layers[neural.MaxLayers-1]
-	total := len(layer.Range)
-	if total == 0 {
-		return outputs
-	}
-
-	values := make([]float64, total)
-	for i, ranges := range layer.Range {
-		values[i] = rangeToRange(outputs[i], ranges[2], ranges[3], ranges[0], ranges[1])
-	}
-	return values
+	return neural.outputRanges().fromRaw(outputs)
 }
 
 func rangeToRange(v float64, fMin float64, fMax float64, tMin float64, tMax float64) float64 {
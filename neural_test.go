@@ -0,0 +1,138 @@
+package neural
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLearnRawTrainsEveryLayer(t *testing.T) {
+	neural := NewNeural([]*Layer{{Inputs: 2, Units: 3}, {Units: 1}})
+	neural.Seed(1)
+	neural.Reset()
+
+	before := make([][]float64, neural.MaxLayers)
+	for i := 0; i < neural.MaxLayers; i++ {
+		before[i] = append([]float64{}, neural.Layers[i].Neurons[0].Weights...)
+	}
+
+	neural.LearnRaw([]float64{0.5, -0.5}, []float64{1})
+
+	for i := 0; i < neural.MaxLayers; i++ {
+		changed := false
+		for w, weight := range neural.Layers[i].Neurons[0].Weights {
+			changed = changed || weight != before[i][w]
+		}
+		if !changed {
+			t.Fatalf("layer %v didn't learn", i)
+		}
+	}
+}
+
+func TestLearnRawSingleLayer(t *testing.T) {
+	neural := NewNeural([]*Layer{{Inputs: 2, Units: 1, Rate: 0.5}})
+	neural.Seed(1)
+	neural.Reset()
+
+	// a single layer is the first one, it used to never learn
+	first := neural.LearnRaw([]float64{1, 0}, []float64{1})
+	last := first
+	for i := 0; i < 100; i++ {
+		last = neural.LearnRaw([]float64{1, 0}, []float64{1})
+	}
+	if !(last < first/2) || math.IsNaN(last) {
+		t.Fatalf("loss went from %v to %v", first, last)
+	}
+}
+
+// grads of a clone of the neural after a forward and backward of every sample
+func gradsOf(neural *Neural, samples ...[][]float64) []float64 {
+	clone := neural.Clone()
+	clone.ZeroGrad()
+	for _, sample := range samples {
+		clone.Forward(sample[0])
+		clone.Backward(sample[1])
+	}
+	return clone.Grads()
+}
+
+func nearGrads(a []float64, b []float64) bool {
+	for p := range a {
+		if math.Abs(a[p]-b[p]) > 1e-12 {
+			return false
+		}
+	}
+	return len(a) == len(b)
+}
+
+func TestBackwardAccumulates(t *testing.T) {
+	neural := NewNeural([]*Layer{{Inputs: 2, Units: 3, Activation: "tanh"}, {Units: 2}})
+	neural.Seed(1)
+	neural.Reset()
+
+	first := [][]float64{{0.5, -0.5}, {1, 0}}
+	second := [][]float64{{-0.3, 0.8}, {0, 1}}
+	one, two := gradsOf(neural, first), gradsOf(neural, second)
+
+	sum := make([]float64, len(one))
+	for p := range sum {
+		sum[p] = one[p] + two[p]
+	}
+	if !nearGrads(gradsOf(neural, first, second), sum) {
+		t.Fatal("the gradients of several Backward are not their sum")
+	}
+
+	// summing two losses of the same forward is a loss with the average target twice
+	neural.ZeroGrad()
+	neural.Forward(first[0])
+	loss := neural.Backward([]float64{1, 0}) + neural.Backward([]float64{0, 0.4})
+	twice := gradsOf(neural, [][]float64{first[0], {0.5, 0.2}}, [][]float64{first[0], {0.5, 0.2}})
+	if !nearGrads(neural.Grads(), twice) || !(loss > 0) {
+		t.Fatalf("gradients of the summed losses %v instead of %v", neural.Grads(), twice)
+	}
+}
+
+func TestStepAndZeroGrad(t *testing.T) {
+	neural := NewNeural([]*Layer{{Inputs: 2, Units: 3, Rate: 0.1}, {Units: 1, Rate: 0.1}})
+	neural.Seed(1)
+	neural.Reset()
+
+	neural.ZeroGrad()
+	neural.Forward([]float64{0.5, -0.5})
+	neural.Backward([]float64{1})
+	neural.Forward([]float64{-0.3, 0.8})
+	neural.Backward([]float64{0})
+
+	params, grads := neural.Params(), neural.Grads()
+	if err := neural.Step(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Step descends the accumulated gradients and keeps them
+	expected := make([]float64, len(params))
+	for p := range params {
+		expected[p] = params[p] - 0.1*grads[p]
+	}
+	if !nearGrads(neural.Params(), expected) || !nearGrads(neural.Grads(), grads) {
+		t.Fatalf("params %v after the step instead of %v", neural.Params(), expected)
+	}
+
+	neural.ZeroGrad()
+	for _, grad := range neural.Grads() {
+		if grad != 0 {
+			t.Fatalf("gradients %v after ZeroGrad", neural.Grads())
+		}
+	}
+}
+
+func TestThinkBetweenForwardAndBackward(t *testing.T) {
+	neural := NewNeural([]*Layer{{Inputs: 2, Units: 3}, {Units: 1}})
+	neural.Forward([]float64{0.5, -0.5})
+	neural.ThinkRaw([]float64{1, 1})
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic of Backward after thinking")
+		}
+	}()
+	neural.Backward([]float64{1})
+}
@@ -1,10 +1,5 @@
 package neural
 
-import (
-	"crypto/rand"
-	"math/big"
-)
-
 // Neuron is a set of weights + bias linked to a layer
 type Neuron struct {
 	MaxInputs int       `json:"-"`
@@ -12,6 +7,8 @@ type Neuron struct {
 	Bias      float64   `json:"Bias"`
 	// Previous momentum of every weight and bias
 	Momentums []float64 `json:"-"`
+	// Gradient of every weight and bias (accumulated since the last ZeroGrad)
+	Gradients []float64 `json:"-"`
 	// Layer to which neuron is linked
 	Layer      *Layer `json:"-"`
 	activation float64
@@ -25,14 +22,15 @@ func NewNeuron(Layer *Layer, MaxInputs int) *Neuron {
 	neuron := &Neuron{
 		MaxInputs: MaxInputs,
 		Weights:   make([]float64, MaxInputs),
-		Bias:      randomFloat(-1.0, 1.0),
+		Bias:      Layer.Random.Range(-1.0, 1.0),
 		Momentums: make([]float64, MaxInputs+1),
+		Gradients: make([]float64, MaxInputs+1),
 		Layer:     Layer,
 		Inputs:    make([]float64, MaxInputs),
 	}
 
 	for i := 0; i < neuron.MaxInputs; i++ {
-		neuron.Weights[i] = randomFloat(-1.0, 1.0)
+		neuron.Weights[i] = Layer.Random.Range(-1.0, 1.0)
 	}
 
 	return neuron
@@ -53,10 +51,15 @@ func (neuron *Neuron) Think(inputs []float64) float64 {
 
 // Optimizer learning by momentum
 func (neuron *Neuron) Optimizer(index int, value float64) float64 {
-	neuron.Momentums[index] = value + (neuron.Layer.Momentum * neuron.Momentums[index])
+	neuron.Momentums[index] = neuron.momentum(index, value)
 	return neuron.Momentums[index]
 }
 
+// momentum is the next momentum of a param without changing it
+func (neuron *Neuron) momentum(index int, value float64) float64 {
+	return value + (neuron.Layer.Momentum * neuron.Momentums[index])
+}
+
 // Clone neuron with same weights, bias, etc
 func (neuron *Neuron) Clone() *Neuron {
 	clone := NewNeuron(neuron.Layer, neuron.MaxInputs)
@@ -71,15 +74,17 @@ func (neuron *Neuron) Clone() *Neuron {
 
 // Mutate randomizing weights/bias based on probability
 func (neuron *Neuron) Mutate(probability float64) {
+	random := neuron.Layer.Random
+
 	for i := 0; i < neuron.MaxInputs; i++ {
-		if probability >= cryptoRandomFloat() {
-			neuron.Weights[i] += randomFloat(-1.0, 1.0)
+		if probability >= random.Float64() {
+			neuron.Weights[i] += random.Range(-1.0, 1.0)
 			neuron.Momentums[i] = 0.0
 		}
 	}
 
-	if probability >= cryptoRandomFloat() {
-		neuron.Bias += randomFloat(-1.0, 1.0)
+	if probability >= random.Float64() {
+		neuron.Bias += random.Range(-1.0, 1.0)
 		neuron.Momentums[neuron.MaxInputs] = 0.0
 	}
 }
@@ -87,16 +92,17 @@ func (neuron *Neuron) Mutate(probability float64) {
 // Crossover two neurons merging weights and bias
 func (neuron *Neuron) Crossover(neuronB Neuron, dominant float64) *Neuron {
 	new := NewNeuron(neuron.Layer, neuron.MaxInputs)
+	random := neuron.Layer.Random
 
 	for i := 0; i < new.MaxInputs; i++ {
-		if cryptoRandomFloat() >= 0.5 {
+		if random.Float64() >= 0.5 {
 			new.Weights[i] = neuron.Weights[i]
 		} else {
 			new.Weights[i] = neuronB.Weights[i]
 		}
 	}
 
-	if cryptoRandomFloat() >= 0.5 {
+	if random.Float64() >= 0.5 {
 		new.Bias = neuron.Bias
 	} else {
 		new.Bias = neuronB.Bias
@@ -107,31 +113,13 @@ func (neuron *Neuron) Crossover(neuronB Neuron, dominant float64) *Neuron {
 
 // Reset weights, bias and momentums
 func (neuron *Neuron) Reset() {
+	random := neuron.Layer.Random
+
 	for i := 0; i < neuron.MaxInputs; i++ {
-		neuron.Weights[i] = randomFloat(-1.0, 1.0)
+		neuron.Weights[i] = random.Range(-1.0, 1.0)
 		neuron.Momentums[i] = 0.0
 	}
 
-	neuron.Bias = randomFloat(-1.0, 1.0)
+	neuron.Bias = random.Range(-1.0, 1.0)
 	neuron.Momentums[neuron.MaxInputs] = 0.0
 }
-
-func randomFloat(min float64, max float64) float64 {
-	return min + cryptoRandomFloat()*(max-min)
-}
-
-func cryptoRandomFloat() float64 {
-	num, err := rand.Int(rand.Reader, big.NewInt(1e17))
-	if err != nil {
-		panic(err)
-	}
-	return float64(num.Int64()) / float64(1e17)
-}
-
-func randomInt(max int64) int {
-	num, err := rand.Int(rand.Reader, big.NewInt(max))
-	if err != nil {
-		panic(err)
-	}
-	return int(num.Int64())
-}
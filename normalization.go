@@ -0,0 +1,402 @@
+package neural
+
+import (
+	"encoding/json"
+	"math"
+)
+
+const (
+	// Added to the variance to avoid dividing by zero
+	normEpsilon = 1e-5
+	// Weight of the new sample on the running statistics of batchnorm
+	normAverage = 0.01
+	// Weight of the statistics of a mini-batch on the running statistics of batchnorm
+	normBatchAverage = 0.1
+)
+
+// Normalization layers have a neuron per input, its only weight is gamma (scale) and the bias is beta (shift)
+// layernorm uses the mean and variance of the inputs of every sample
+// batchnorm learning mini-batches (Batch > 1) normalizes with the mean and variance of the batch and backpropagates
+// through them, then the running statistics move towards the ones of the batch. Learning one sample at a time
+// (LearnRaw or a batch of one sample) normalizes with the running statistics, they are updated after every
+// training forward and are constants for the backpropagation. Inference always uses the running statistics
+
+// normState is what a normalization forward keeps for the backward
+type normState struct {
+	neurons    neuronState
+	deviations []float64
+}
+
+// normKind is layernorm, it keeps the standard deviations used by the last forward
+type normKind struct {
+	NeuronKind
+	deviations []float64
+}
+
+// batchNormKind also has running statistics (Mean and Variance of the layer) and the statistics of mini-batches
+type batchNormKind struct {
+	normKind
+	// Inputs of the last training forward that updates the running statistics
+	inputs []float64
+	// Statistics of the mini-batch and the means of the errors that go through them
+	batchMean     []float64
+	batchVariance []float64
+	batchScaled   []float64
+	batchProduct  []float64
+}
+
+func (layer *Layer) isNorm() bool {
+	return layer.Type == "batchnorm" || layer.Type == "layernorm"
+}
+
+// New creates the neurons of a normalization layer (default activation is linear)
+func (*normKind) New(layer *Layer) {
+	if layer.Units == 0 {
+		layer.Units = layer.Inputs
+	}
+	if layer.Units != layer.Inputs {
+		panic("need same inputs and units in normalization layers")
+	}
+	if layer.Activation == "" {
+		layer.Activation = "linear"
+	}
+
+	layer.Neurons = make([]*Neuron, layer.Units)
+	for i := 0; i < layer.Units; i++ {
+		layer.Neurons[i] = NewNeuron(layer, 1)
+	}
+
+	layer.resetNorm()
+}
+
+// Params are gamma and beta of the units, they are only regularized like biases
+func (*normKind) Params(layer *Layer, units []int) []Param {
+	params := layer.neuronParams(units, true)
+	for p := range params {
+		params[p].Bias = true
+	}
+	return params
+}
+
+func (kind *normKind) State(layer *Layer) interface{} {
+	return normState{layer.neuronState(), kind.deviations}
+}
+
+func (kind *normKind) SetState(layer *Layer, state interface{}) {
+	norm := state.(normState)
+	layer.setNeuronState(norm.neurons)
+	kind.deviations = norm.deviations
+}
+
+func (kind *normKind) Import(layer *Layer, data json.RawMessage) error {
+	kind.NeuronKind.Import(layer, data)
+	layer.Inputs = layer.Units
+	return nil
+}
+
+func (kind *normKind) Reset(layer *Layer) {
+	kind.NeuronKind.Reset(layer)
+	layer.resetNorm()
+}
+
+func (*normKind) Resize(layer *Layer, inputs int) {
+	layer.resizeNorm(inputs)
+}
+
+// resetNorm starts as identity (gamma 1, beta 0) and the running statistics as standard normal
+func (layer *Layer) resetNorm() {
+	for _, neuron := range layer.Neurons {
+		neuron.Weights[0] = 1.0
+		neuron.Bias = 0.0
+	}
+
+	if layer.Type == "batchnorm" {
+		layer.Mean = make([]float64, layer.Units)
+		layer.Variance = make([]float64, layer.Units)
+		for i := range layer.Variance {
+			layer.Variance[i] = 1.0
+		}
+	}
+}
+
+// Forward of layernorm uses the mean and variance of the inputs
+func (kind *normKind) Forward(layer *Layer, inputs []float64, outs []float64, training bool) {
+	mean, variance := meanVariance(inputs)
+	deviation := math.Sqrt(variance + normEpsilon)
+
+	kind.deviations = make([]float64, layer.Units)
+	for i := range kind.deviations {
+		kind.deviations[i] = deviation
+		layer.Neurons[i].Inputs[0] = (inputs[i] - mean) / deviation
+	}
+
+	layer.scaleNorm(outs)
+}
+
+// Forward of batchnorm uses the running statistics (or the ones of the mini-batch while training)
+func (kind *batchNormKind) Forward(layer *Layer, inputs []float64, outs []float64, training bool) {
+	mean, variance := layer.Mean, layer.Variance
+	if training && kind.batchMean != nil {
+		mean, variance = kind.batchMean, kind.batchVariance
+	}
+
+	kind.deviations = make([]float64, layer.Units)
+	for i := range kind.deviations {
+		kind.deviations[i] = math.Sqrt(variance[i] + normEpsilon)
+		layer.Neurons[i].Inputs[0] = (inputs[i] - mean[i]) / kind.deviations[i]
+	}
+
+	if training && kind.updatesStatistics(layer) {
+		kind.inputs = append([]float64{}, inputs...)
+		if !layer.replica {
+			layer.updateStatistics(inputs)
+		}
+	}
+
+	layer.scaleNorm(outs)
+}
+
+// scaleNorm applies gamma and beta to the normalized inputs of the neurons
+func (layer *Layer) scaleNorm(outs []float64) {
+	for i, neuron := range layer.Neurons {
+		neuron.activation = layer.Forward(neuron.Weights[0]*neuron.Inputs[0] + neuron.Bias)
+		outs[i] = neuron.activation
+	}
+}
+
+// updatesStatistics is true for batchnorm layers that move their running statistics on every training forward
+// Frozen layers keep them as they are (like pretrained ones), mini-batches update them at the end of the batch
+func (kind *batchNormKind) updatesStatistics(layer *Layer) bool {
+	return !layer.Frozen && kind.batchMean == nil
+}
+
+// updateStatistics moves the running mean and variance of batchnorm towards a sample
+func (layer *Layer) updateStatistics(inputs []float64) {
+	for i, input := range inputs {
+		diff := input - layer.Mean[i]
+		layer.Mean[i] += normAverage * diff
+		layer.Variance[i] = (1.0 - normAverage) * (layer.Variance[i] + normAverage*diff*diff)
+	}
+}
+
+func (kind *batchNormKind) Clone(layer *Layer, clone *Layer) {
+	kind.NeuronKind.Clone(layer, clone)
+	clone.Mean = append([]float64(nil), layer.Mean...)
+	clone.Variance = append([]float64(nil), layer.Variance...)
+}
+
+// Crossover also averages the running statistics
+func (kind *batchNormKind) Crossover(layer *Layer, other *Layer, child *Layer, dominant float64) {
+	kind.NeuronKind.Crossover(layer, other, child, dominant)
+	for i := range child.Mean {
+		child.Mean[i] = (layer.Mean[i] + other.Mean[i]) / 2.0
+		child.Variance[i] = (layer.Variance[i] + other.Variance[i]) / 2.0
+	}
+}
+
+func (kind *batchNormKind) Import(layer *Layer, data json.RawMessage) error {
+	kind.normKind.Import(layer, data)
+	if len(layer.Mean) != layer.Units {
+		layer.resetNorm()
+	}
+	return nil
+}
+
+func (*batchNormKind) Resize(layer *Layer, inputs int) {
+	layer.resizeNorm(inputs)
+	layer.resizeStatistics(inputs)
+}
+
+func (*batchNormKind) Elementwise(layer *Layer) bool {
+	return true
+}
+
+func (*batchNormKind) WidenUnits(layer *Layer, sources []int) {
+	layer.widenNeurons(sources)
+	for _, source := range sources[len(layer.Mean):] {
+		layer.Mean = append(layer.Mean, layer.Mean[source])
+		layer.Variance = append(layer.Variance, layer.Variance[source])
+	}
+	layer.Inputs = layer.Units
+}
+
+func (kind *batchNormKind) WidenInputs(layer *Layer, sources []int, copies []int) {
+	kind.WidenUnits(layer, sources)
+}
+
+// Sync copies the running statistics and the ones of the mini-batch
+func (kind *batchNormKind) Sync(layer *Layer, master *Layer) {
+	from := master.kind().(*batchNormKind)
+	copy(layer.Mean, master.Mean)
+	copy(layer.Variance, master.Variance)
+	kind.batchMean, kind.batchVariance = from.batchMean, from.batchVariance
+	kind.batchScaled, kind.batchProduct = from.batchScaled, from.batchProduct
+}
+
+func (*batchNormKind) Batched(layer *Layer) bool {
+	return !layer.Frozen
+}
+
+// SetBatch sets the mean and variance of the inputs of every sample of a mini-batch
+func (kind *batchNormKind) SetBatch(layer *Layer, inputs [][]float64) {
+	kind.batchMean = make([]float64, layer.Units)
+	kind.batchVariance = make([]float64, layer.Units)
+
+	values := make([]float64, len(inputs))
+	for i := 0; i < layer.Units; i++ {
+		for s, sample := range inputs {
+			values[s] = sample[i]
+		}
+		kind.batchMean[i], kind.batchVariance[i] = meanVariance(values)
+	}
+}
+
+// BatchErrors are the scaled errors (gamma * delta) of the last backward followed by their product with the normalized inputs
+func (*batchNormKind) BatchErrors(layer *Layer) []float64 {
+	errors := make([]float64, 2*layer.Units)
+	for i, neuron := range layer.Neurons {
+		errors[i] = neuron.Weights[0] * neuron.delta
+		errors[layer.Units+i] = errors[i] * neuron.Inputs[0]
+	}
+	return errors
+}
+
+// SetBatchErrors sets the means over a mini-batch of the scaled errors and of their products,
+// so the backward of every sample also propagates through the mean and variance of the batch
+func (kind *batchNormKind) SetBatchErrors(layer *Layer, errors [][]float64) {
+	kind.batchScaled = make([]float64, layer.Units)
+	kind.batchProduct = make([]float64, layer.Units)
+
+	for _, sample := range errors {
+		for i := 0; i < layer.Units; i++ {
+			kind.batchScaled[i] += sample[i] / float64(len(errors))
+			kind.batchProduct[i] += sample[layer.Units+i] / float64(len(errors))
+		}
+	}
+}
+
+// Sample is the inputs of the last forward if it would have updated the running statistics
+func (kind *batchNormKind) Sample(layer *Layer) []float64 {
+	if !kind.updatesStatistics(layer) {
+		return nil
+	}
+	return kind.inputs
+}
+
+// EndBatch moves the running statistics towards the ones of the mini-batch (unbiased variance) and clears them
+// Without statistics of the batch they are updated with the inputs of every sample in order
+func (kind *batchNormKind) EndBatch(layer *Layer, samples [][]float64) {
+	if kind.batchMean == nil {
+		for _, inputs := range samples {
+			if inputs != nil {
+				layer.updateStatistics(inputs)
+			}
+		}
+		return
+	}
+
+	for i := range layer.Mean {
+		layer.Mean[i] += normBatchAverage * (kind.batchMean[i] - layer.Mean[i])
+		unbiased := kind.batchVariance[i] * float64(len(samples)) / float64(len(samples)-1)
+		layer.Variance[i] += normBatchAverage * (unbiased - layer.Variance[i])
+	}
+
+	kind.batchMean, kind.batchVariance, kind.batchScaled, kind.batchProduct = nil, nil, nil, nil
+}
+
+// resizeNorm changes the amount of inputs and units keeping gamma and beta of the units that still fit (new ones are identity)
+func (layer *Layer) resizeNorm(inputs int) {
+	neurons := make([]*Neuron, inputs)
+	for i := range neurons {
+		if i < layer.Units {
+			neurons[i] = layer.Neurons[i]
+		} else {
+			neurons[i] = NewNeuron(layer, 1)
+			neurons[i].Weights[0] = 1.0
+			neurons[i].Bias = 0.0
+		}
+	}
+
+	layer.Neurons = neurons
+	layer.Inputs = inputs
+	layer.Units = inputs
+}
+
+// resizeStatistics keeps the running statistics that still fit (new ones are standard normal)
+func (layer *Layer) resizeStatistics(units int) {
+	mean, variance := make([]float64, units), make([]float64, units)
+	for i := range mean {
+		variance[i] = 1.0
+		if i < len(layer.Mean) {
+			mean[i], variance[i] = layer.Mean[i], layer.Variance[i]
+		}
+	}
+	layer.Mean, layer.Variance = mean, variance
+}
+
+// scaledErrors computes the gradients of gamma and beta, and returns the errors scaled by gamma
+func (layer *Layer) scaledErrors(errors []float64) []float64 {
+	scaled := make([]float64, layer.Units)
+
+	for n, neuron := range layer.Neurons {
+		neuron.error = errors[n]
+		neuron.delta = layer.Backward(neuron.activation) * neuron.error
+		neuron.Gradients[0] -= neuron.Inputs[0] * neuron.delta
+		neuron.Gradients[1] -= neuron.delta
+		scaled[n] = neuron.Weights[0] * neuron.delta
+	}
+
+	return scaled
+}
+
+// Backward of layernorm also propagates through the mean and variance of the inputs
+func (kind *normKind) Backward(layer *Layer, errors []float64) []float64 {
+	inputErrors := make([]float64, layer.Inputs)
+	scaled := layer.scaledErrors(errors)
+
+	meanScaled, meanProduct := 0.0, 0.0
+	for n, neuron := range layer.Neurons {
+		meanScaled += scaled[n]
+		meanProduct += scaled[n] * neuron.Inputs[0]
+	}
+	meanScaled /= float64(layer.Units)
+	meanProduct /= float64(layer.Units)
+
+	for n, neuron := range layer.Neurons {
+		inputErrors[n] = (scaled[n] - meanScaled - neuron.Inputs[0]*meanProduct) / kind.deviations[n]
+	}
+
+	return inputErrors
+}
+
+// Backward of batchnorm propagates through the statistics of the mini-batch if there are (running statistics are constants)
+func (kind *batchNormKind) Backward(layer *Layer, errors []float64) []float64 {
+	inputErrors := make([]float64, layer.Inputs)
+	scaled := layer.scaledErrors(errors)
+
+	for i, neuron := range layer.Neurons {
+		inputErrors[i] = scaled[i]
+		if kind.batchScaled != nil {
+			inputErrors[i] -= kind.batchScaled[i] + neuron.Inputs[0]*kind.batchProduct[i]
+		}
+		inputErrors[i] /= kind.deviations[i]
+	}
+
+	return inputErrors
+}
+
+func meanVariance(values []float64) (float64, float64) {
+	mean := 0.0
+	for _, value := range values {
+		mean += value
+	}
+	mean /= float64(len(values))
+
+	variance := 0.0
+	for _, value := range values {
+		variance += (value - mean) * (value - mean)
+	}
+	variance /= float64(len(values))
+
+	return mean, variance
+}
@@ -0,0 +1,216 @@
+package neural
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLayerNormOutputs(t *testing.T) {
+	neural := NewNeural([]*Layer{{Inputs: 4, Type: "layernorm"}})
+
+	outputs := neural.ThinkRaw([]float64{1, 2, 3, 10})
+	mean, variance := meanVariance(outputs)
+	if math.Abs(mean) > 1e-12 || math.Abs(variance-1.0) > 1e-3 {
+		t.Fatalf("outputs %v have mean %v and variance %v", outputs, mean, variance)
+	}
+}
+
+func TestBatchNormRunningStatistics(t *testing.T) {
+	neural := NewNeural([]*Layer{{Inputs: 2, Type: "batchnorm"}})
+
+	// inference doesn't change the statistics
+	neural.ThinkRaw([]float64{3, -2})
+	if neural.Layers[0].Mean[0] != 0 || neural.Layers[0].Variance[0] != 1 {
+		t.Fatalf("inference changed the statistics %v %v", neural.Layers[0].Mean, neural.Layers[0].Variance)
+	}
+
+	// training normalizes with the previous statistics and then moves them towards the sample
+	outputs := neural.forward([]float64{3, -2}, true)
+	if math.Abs(outputs[0]-3/math.Sqrt(1+normEpsilon)) > 1e-12 {
+		t.Fatalf("output %v", outputs[0])
+	}
+	layer := neural.Layers[0]
+	if math.Abs(layer.Mean[0]-0.03) > 1e-12 || math.Abs(layer.Mean[1]+0.02) > 1e-12 {
+		t.Fatalf("running mean %v", layer.Mean)
+	}
+	if math.Abs(layer.Variance[0]-0.99*(1+0.01*9)) > 1e-12 {
+		t.Fatalf("running variance %v", layer.Variance)
+	}
+}
+
+func TestNormGradients(t *testing.T) {
+	neural := NewNeural([]*Layer{
+		{Inputs: 3, Units: 4, Activation: "tanh"},
+		{Type: "layernorm"},
+		{Units: 3, Activation: "tanh"},
+		{Type: "batchnorm", Activation: "sigmoid"},
+		{Units: 2, Activation: "linear"},
+	})
+	neural.Seed(1)
+	neural.Reset()
+	neural.Layers[3].Mean = []float64{0.2, -0.1, 0.3}
+	neural.Layers[3].Variance = []float64{0.5, 2, 1.5}
+
+	inputs, outputs := []float64{0.3, -0.8, 0.5}, []float64{1, 0}
+	// half of the squared error of the inference outputs (running statistics are constants)
+	loss := func() float64 {
+		sum := 0.0
+		for o, output := range neural.ThinkRaw(inputs) {
+			sum += (outputs[o] - output) * (outputs[o] - output) / 2.0
+		}
+		return sum
+	}
+
+	neural.backward(neural.forward(inputs, false), outputs)
+	analytic := neural.Grads()
+	params := neural.Params()
+
+	const epsilon = 1e-5
+	for p := range params {
+		shifted := append([]float64{}, params...)
+		shifted[p] += epsilon
+		neural.SetParams(shifted)
+		plus := loss()
+
+		shifted[p] -= 2 * epsilon
+		neural.SetParams(shifted)
+		minus := loss()
+
+		if numeric := (plus - minus) / (2 * epsilon); math.Abs(analytic[p]-numeric) > 1e-7 {
+			t.Fatalf("param %v: analytic %v numeric %v", p, analytic[p], numeric)
+		}
+	}
+}
+
+func TestNormRoundTrip(t *testing.T) {
+	neural := NewNeural([]*Layer{{Inputs: 2, Units: 3}, {Type: "batchnorm"}, {Type: "layernorm"}, {Units: 1}})
+	neural.Seed(1)
+	neural.Reset()
+	neural.LearnsRaw([][][]float64{{{0, 1}, {1}}, {{1, 0}, {0}}, {{1, 1}, {1}}})
+
+	encoded, err := neural.Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := NewNeural([]*Layer{})
+	if err := imported.Import(encoded); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range neural.Layers[1].Mean {
+		if imported.Layers[1].Mean[i] != neural.Layers[1].Mean[i] || imported.Layers[1].Variance[i] != neural.Layers[1].Variance[i] {
+			t.Fatal("running statistics changed")
+		}
+	}
+	if a, b := neural.ThinkRaw([]float64{0.4, 0.7}), imported.ThinkRaw([]float64{0.4, 0.7}); a[0] != b[0] {
+		t.Fatalf("outputs changed from %v to %v", a, b)
+	}
+}
+
+// batchLoss learns a batch without updating the weights and returns the average loss (half of the squared error)
+// and the average gradients
+func batchLoss(neural *Neural, batch [][][]float64) (float64, []float64) {
+	neural.replicate(2)
+	slots := neural.learnBatch(batch, 2)
+
+	loss, grads := 0.0, make([]float64, len(slots[0].grads))
+	for _, slot := range slots {
+		loss += slot.loss * float64(neural.Layers[neural.MaxLayers-1].Units) / 2.0
+		for p, grad := range slot.grads {
+			grads[p] += grad / float64(len(slots))
+		}
+	}
+	for i := 0; i < neural.MaxLayers; i++ {
+		if kind, ok := neural.Layers[i].kind().(*batchNormKind); ok && kind.batchMean != nil {
+			kind.EndBatch(neural.Layers[i], make([][]float64, len(slots)))
+		}
+	}
+
+	return loss / float64(len(slots)), grads
+}
+
+func TestBatchNormGradients(t *testing.T) {
+	neural := NewNeural([]*Layer{
+		{Inputs: 3, Units: 4, Activation: "tanh"},
+		{Type: "batchnorm"},
+		{Units: 3, Activation: "tanh"},
+		{Type: "batchnorm", Activation: "sigmoid"},
+		{Units: 2, Activation: "linear"},
+	})
+	neural.Seed(1)
+	neural.Reset()
+
+	batch := [][][]float64{
+		{{0.3, -0.8, 0.5}, {1, 0}},
+		{{0.1, 0.4, -0.9}, {0, 1}},
+		{{-0.6, 0.2, 0.7}, {1, 1}},
+		{{0.9, -0.1, 0.2}, {0, 0}},
+		{{-0.2, -0.5, -0.4}, {1, 0}},
+	}
+
+	params := neural.Params()
+	_, analytic := batchLoss(neural, batch)
+
+	const epsilon = 1e-5
+	for p := range params {
+		shifted := append([]float64{}, params...)
+		shifted[p] += epsilon
+		neural.SetParams(shifted)
+		plus, _ := batchLoss(neural, batch)
+
+		shifted[p] -= 2 * epsilon
+		neural.SetParams(shifted)
+		minus, _ := batchLoss(neural, batch)
+
+		numeric := (plus - minus) / (2 * epsilon)
+		scale := math.Max(math.Max(math.Abs(analytic[p]), math.Abs(numeric)), 1e-8)
+		if relative := math.Abs(analytic[p]-numeric) / scale; relative > 1e-5 && math.Abs(analytic[p]-numeric) > 1e-9 {
+			t.Fatalf("param %v: analytic %v numeric %v", p, analytic[p], numeric)
+		}
+	}
+}
+
+func TestBatchNormStatistics(t *testing.T) {
+	neural := NewNeural([]*Layer{{Inputs: 2, Type: "batchnorm", Activation: "linear"}})
+	neural.Batch = 4
+
+	// the outputs of a batch are normalized with its own mean and variance
+	batch := [][][]float64{{{1, 10}, {0, 0}}, {{2, 20}, {0, 0}}, {{3, 30}, {0, 0}}, {{4, 40}, {0, 0}}}
+	neural.replicate(1)
+	neural.learnBatch(batch, 1)
+
+	layer, kind := neural.Layers[0], neural.Layers[0].kind().(*batchNormKind)
+	if kind.batchMean[0] != 2.5 || kind.batchMean[1] != 25 || kind.batchVariance[0] != 1.25 {
+		t.Fatalf("batch statistics %v %v", kind.batchMean, kind.batchVariance)
+	}
+
+	// the running statistics move towards the batch ones (unbiased variance) and inference uses them
+	kind.EndBatch(layer, make([][]float64, len(batch)))
+	if math.Abs(layer.Mean[0]-0.25) > 1e-12 || math.Abs(layer.Variance[1]-(0.9+0.1*500.0/3.0)) > 1e-9 {
+		t.Fatalf("running statistics %v %v", layer.Mean, layer.Variance)
+	}
+	if kind.batchMean != nil || kind.batchScaled != nil {
+		t.Fatal("batch statistics after the batch")
+	}
+
+	expected := (1.0 - layer.Mean[0]) / math.Sqrt(layer.Variance[0]+normEpsilon)
+	if outputs := neural.ThinkRaw([]float64{1, 10}); math.Abs(outputs[0]-expected) > 1e-12 {
+		t.Fatalf("inference output %v expected %v", outputs[0], expected)
+	}
+}
+
+func TestFrozenBatchNormStatistics(t *testing.T) {
+	for _, batch := range []int{1, 3} {
+		neural := NewNeural([]*Layer{{Inputs: 2, Units: 2}, {Type: "batchnorm", Frozen: true}, {Units: 1}})
+		neural.Batch = batch
+		mean, variance := append([]float64{}, neural.Layers[1].Mean...), append([]float64{}, neural.Layers[1].Variance...)
+
+		neural.LearnsRaw([][][]float64{{{0, 1}, {1}}, {{1, 0}, {0}}, {{1, 1}, {1}}})
+
+		for i := range mean {
+			if neural.Layers[1].Mean[i] != mean[i] || neural.Layers[1].Variance[i] != variance[i] {
+				t.Fatalf("batch %v changed the statistics of a frozen layer", batch)
+			}
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package neural
+
+import (
+	"math"
+	"sync"
+)
+
+// Mini-batches (Batch > 1) update the weights once per batch with the average gradient of its samples.
+// Workers are replicas of the neural computing the gradients of different samples of the same batch,
+// every sample has its own slot and the slots are added in order, so any amount of workers gives the same result.
+
+// batchSlot keeps what a sample of the batch contributes to the update
+type batchSlot struct {
+	loss  float64
+	grads []float64
+	// What every layer of a BatchKind keeps of the sample (like the inputs of batchnorm for the running statistics)
+	samples [][]float64
+}
+
+// learnBatches learns a raw dataset in mini-batches and returns the average loss
+func (neural *Neural) learnBatches(dataset [][][]float64) float64 {
+	workers := neural.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	loss := 0.0
+	for from := 0; from < len(dataset); from += neural.Batch {
+		to := from + neural.Batch
+		if to > len(dataset) {
+			to = len(dataset)
+		}
+
+		neural.replicate(workers)
+		slots := neural.learnBatch(dataset[from:to], workers)
+		for _, slot := range slots {
+			loss += slot.loss
+		}
+
+		if err := neural.reduce(slots); err != nil {
+			return math.NaN()
+		}
+	}
+
+	return loss / float64(len(dataset))
+}
+
+// learnBatch computes the gradients of every sample of a batch with the workers
+// Layers that use the batch (like batchnorm) need the inputs of every sample, so first there is a pass for every one
+// of them (the first one before the next) and a pass for the errors of the batch (the last one before the previous)
+func (neural *Neural) learnBatch(batch [][][]float64, workers int) []batchSlot {
+	slots := make([]batchSlot, len(batch))
+
+	// every sample has its own source of randomness (dropout) no matter which worker takes it, and in every pass
+	seeds := make([]int64, len(batch))
+	if neural.Random != nil {
+		for s := range seeds {
+			seeds[s] = int64(neural.Random.Uint64())
+		}
+	}
+
+	params := neural.Params()
+	for w := 0; w < workers && w < len(batch); w++ {
+		neural.replicas[w].copyFrom(neural, params)
+	}
+
+	batched := neural.batchedLayers(len(batch))
+	for _, l := range batched {
+		inputs := make([][]float64, len(batch))
+		neural.eachSample(batch, workers, seeds, func(replica *Neural, s int) {
+			inputs[s] = batch[s][0]
+			for i := 0; i < l; i++ {
+				inputs[s] = replica.Layers[i].forward(inputs[s], true)
+			}
+		})
+		neural.Layers[l].batched().SetBatch(neural.Layers[l], inputs)
+	}
+
+	for n := len(batched) - 1; n >= 0; n-- {
+		errors := make([][]float64, len(batch))
+		neural.eachSample(batch, workers, seeds, func(replica *Neural, s int) {
+			replica.ZeroGrad()
+			replica.keepForward(batch[s][0], true)
+			replica.Backward(batch[s][1])
+			layer := replica.Layers[batched[n]]
+			errors[s] = layer.batched().BatchErrors(layer)
+		})
+		neural.Layers[batched[n]].batched().SetBatchErrors(neural.Layers[batched[n]], errors)
+	}
+
+	neural.eachSample(batch, workers, seeds, func(replica *Neural, s int) {
+		replica.ZeroGrad()
+		replica.keepForward(batch[s][0], true)
+		slots[s].loss = replica.Backward(batch[s][1])
+		slots[s].grads = replica.Grads()
+
+		slots[s].samples = make([][]float64, replica.MaxLayers)
+		for i := 0; i < replica.MaxLayers; i++ {
+			if layer := replica.Layers[i]; layer.batched() != nil {
+				slots[s].samples[i] = layer.batched().Sample(layer)
+			}
+		}
+	})
+
+	return slots
+}
+
+// batchedLayers are the indices of the layers using the batch (it needs two samples at least)
+func (neural *Neural) batchedLayers(samples int) []int {
+	batched := []int{}
+	for i := 0; i < neural.MaxLayers && samples > 1; i++ {
+		if layer := neural.Layers[i]; layer.batched() != nil && layer.batched().Batched(layer) {
+			batched = append(batched, i)
+		}
+	}
+	return batched
+}
+
+// eachSample runs fn for every sample of the batch with the replicas of the workers
+func (neural *Neural) eachSample(batch [][][]float64, workers int, seeds []int64, fn func(replica *Neural, s int)) {
+	samples := make(chan int, len(batch))
+	for s := range batch {
+		samples <- s
+	}
+	close(samples)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers && w < len(batch); w++ {
+		replica := neural.replicas[w]
+		for i := 0; i < neural.MaxLayers; i++ {
+			if sync, ok := replica.Layers[i].kind().(SyncKind); ok {
+				sync.Sync(replica.Layers[i], neural.Layers[i])
+			}
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for s := range samples {
+				var random *Random
+				if neural.Random != nil {
+					random = NewRandom(seeds[s])
+				}
+				for i := 0; i < replica.MaxLayers; i++ {
+					replica.Layers[i].Random = random
+				}
+
+				fn(replica, s)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// reduce averages the gradients of the slots in order, ends the batch of the layers that use it and steps the weights
+func (neural *Neural) reduce(slots []batchSlot) error {
+	grads := make([]float64, len(slots[0].grads))
+	for _, slot := range slots {
+		for p, grad := range slot.grads {
+			grads[p] += grad
+		}
+	}
+	for p := range grads {
+		grads[p] /= float64(len(slots))
+	}
+	neural.setGrads(grads)
+
+	for i := 0; i < neural.MaxLayers; i++ {
+		if layer := neural.Layers[i]; layer.batched() != nil {
+			samples := make([][]float64, len(slots))
+			for s, slot := range slots {
+				samples[s] = slot.samples[i]
+			}
+			layer.batched().EndBatch(layer, samples)
+		}
+	}
+
+	return neural.Step()
+}
+
+// replicate creates the replicas of the workers (again if the shape of any layer changed)
+func (neural *Neural) replicate(workers int) {
+	if len(neural.replicas) == workers && neural.replicas[0].matches(neural) {
+		return
+	}
+
+	// cloning draws random weights (replaced on every batch), the sources are restored so the workers don't change the results
+	states := map[*Random]uint64{}
+	if neural.Random != nil {
+		states[neural.Random] = neural.Random.State
+	}
+	for i := 0; i < neural.MaxLayers; i++ {
+		if random := neural.Layers[i].Random; random != nil {
+			states[random] = random.State
+		}
+	}
+
+	neural.replicas = make([]*Neural, workers)
+	for w := range neural.replicas {
+		neural.replicas[w] = neural.Clone()
+		for i := 0; i < neural.MaxLayers; i++ {
+			neural.replicas[w].Layers[i].replica = true
+		}
+	}
+
+	for random, state := range states {
+		random.State = state
+	}
+}
+
+// matches is true if the layers of a replica have the same shape as the ones of the master
+func (neural *Neural) matches(master *Neural) bool {
+	if neural.MaxLayers != master.MaxLayers {
+		return false
+	}
+	for i := 0; i < master.MaxLayers; i++ {
+		if neural.Layers[i].shape() != master.Layers[i].shape() {
+			return false
+		}
+	}
+	return true
+}
+
+// copyFrom sets the weights (params of the master) and config of the master to a replica (eachSample syncs the rest)
+// The config can change between batches (SetActivation, Dropout, Frozen, etc) without changing the shape
+func (neural *Neural) copyFrom(master *Neural, params []float64) {
+	neural.SetParams(params)
+
+	for i := 0; i < master.MaxLayers; i++ {
+		layer, from := neural.Layers[i], master.Layers[i]
+
+		layer.Activation, layer.Forward, layer.Backward = from.Activation, from.Forward, from.Backward
+		layer.Frozen = from.Frozen
+		layer.Dropout, layer.AlphaDropout = from.Dropout, from.AlphaDropout
+		layer.Stateful = from.Stateful
+		layer.Config = from.Config
+	}
+}
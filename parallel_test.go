@@ -0,0 +1,86 @@
+package neural
+
+import (
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+// batchDataset has a last batch of one sample with a Batch of 4
+func batchDataset() [][][]float64 {
+	dataset := [][][]float64{}
+	for i := 0; i < 9; i++ {
+		x := float64(i)/8.0 - 0.5
+		dataset = append(dataset, [][]float64{{x, x * x, -x}, {x*0.5 + 0.5}})
+	}
+	return dataset
+}
+
+func TestWorkersSameResult(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(8))
+
+	learn := func(workers int) *Neural {
+		neural := NewNeural([]*Layer{
+			{Inputs: 3, Units: 6, Activation: "relu", Dropout: 0.2},
+			{Type: "batchnorm"},
+			{Units: 4, Activation: "tanh"},
+			{Type: "batchnorm", Frozen: true},
+			{Units: 1},
+		})
+		neural.Seed(1)
+		neural.Reset()
+		neural.Batch = 4
+		neural.Workers = workers
+
+		for epoch := 0; epoch < 5; epoch++ {
+			neural.LearnsRaw(batchDataset())
+		}
+		return neural
+	}
+
+	expected := learn(1)
+	for _, workers := range []int{2, 3, 8} {
+		neural := learn(workers)
+		if !reflect.DeepEqual(neural.Params(), expected.Params()) {
+			t.Fatalf("params with %v workers are different", workers)
+		}
+		for _, i := range []int{1, 3} {
+			if !reflect.DeepEqual(neural.Layers[i].Mean, expected.Layers[i].Mean) || !reflect.DeepEqual(neural.Layers[i].Variance, expected.Layers[i].Variance) {
+				t.Fatalf("running statistics of layer %v with %v workers are different", i, workers)
+			}
+		}
+	}
+}
+
+func TestWorkersFollowConfig(t *testing.T) {
+	learn := func() *Neural {
+		neural := NewNeural([]*Layer{{Inputs: 3, Units: 5}, {Units: 4}, {Units: 1}})
+		neural.Seed(1)
+		neural.Reset()
+		neural.Batch = 4
+		neural.Workers = 2
+		neural.LearnsRaw(batchDataset())
+
+		neural.Layers[0].Activation = "tanh"
+		neural.Layers[0].SetActivation("tanh")
+		neural.Layers[1].Frozen = true
+		return neural
+	}
+
+	// the expected one creates its replicas again with the current config
+	neural, expected := learn(), learn()
+	expected.replicas = nil
+	neural.LearnsRaw(batchDataset())
+	expected.LearnsRaw(batchDataset())
+
+	if !reflect.DeepEqual(neural.Params(), expected.Params()) {
+		t.Fatal("the replicas didn't follow the config of the neural")
+	}
+
+	// and a different shape creates them again
+	neural.InsertLayer(1, &Layer{Units: 3})
+	neural.LearnsRaw(batchDataset())
+	if len(neural.replicas[0].Layers) != 4 {
+		t.Fatal("the replicas kept the previous shape")
+	}
+}
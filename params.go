@@ -0,0 +1,163 @@
+package neural
+
+import (
+	"fmt"
+)
+
+// Parameters are flattened in a stable order: layer by layer and, by default, neuron by neuron
+// with the weights of every neuron (one per input) followed by its bias.
+// Kinds that keep them in another layout (or don't use some of them, like the bias of embedding) list them in their own order.
+// Gradients use the same order, they are the derivatives of half the squared error
+// accumulated since the last ZeroGrad, so an update is weight -= rate * gradient (before momentum).
+
+// Param is a trainable value of a layer with its gradient and momentum, wherever the layer keeps them
+type Param struct {
+	Value    *float64
+	Gradient *float64
+	Momentum *float64
+	// Unit is the neuron of the param, sparse kinds only update the units of their rows
+	Unit int
+	// Bias params (biases and the gamma and beta of normalizations) are only regularized with RegularizeBias
+	Bias bool
+}
+
+// neuronParams are the weights and bias of the neurons of the units (all of them if units is nil)
+func (layer *Layer) neuronParams(units []int, bias bool) []Param {
+	if units == nil {
+		units = make([]int, len(layer.Neurons))
+		for n := range units {
+			units[n] = n
+		}
+	}
+
+	params := []Param{}
+	for _, n := range units {
+		neuron := layer.Neurons[n]
+		for w := range neuron.Weights {
+			params = append(params, Param{&neuron.Weights[w], &neuron.Gradients[w], &neuron.Momentums[w], n, false})
+		}
+		if bias {
+			params = append(params, Param{&neuron.Bias, &neuron.Gradients[neuron.MaxInputs], &neuron.Momentums[neuron.MaxInputs], n, true})
+		}
+	}
+	return params
+}
+
+// params of the units in the order of Params (all of them if units is nil)
+func (layer *Layer) params(units []int) []Param {
+	return layer.kind().Params(layer, units)
+}
+
+// NumParams is the amount of weights and biases of the layer
+func (layer *Layer) NumParams() int {
+	return len(layer.params(nil))
+}
+
+// Params returns a copy of the weights and biases of the layer
+func (layer *Layer) Params() []float64 {
+	params := layer.params(nil)
+	values := make([]float64, len(params))
+	for p, param := range params {
+		values[p] = *param.Value
+	}
+	return values
+}
+
+// SetParams replaces the weights and biases of the layer
+func (layer *Layer) SetParams(values []float64) error {
+	params := layer.params(nil)
+	if len(values) != len(params) {
+		return fmt.Errorf("layer has %v params but got %v", len(params), len(values))
+	}
+
+	for p, param := range params {
+		*param.Value = values[p]
+	}
+	return nil
+}
+
+// Grads returns a copy of the gradients of the layer
+func (layer *Layer) Grads() []float64 {
+	params := layer.params(nil)
+	grads := make([]float64, len(params))
+	for p, param := range params {
+		grads[p] = *param.Gradient
+	}
+	return grads
+}
+
+// setGrads replaces the gradients of the layer
+func (layer *Layer) setGrads(grads []float64) {
+	params := layer.params(nil)
+	for p, param := range params {
+		*param.Gradient = grads[p]
+	}
+
+	// sparse kinds update the units with gradients
+	if sparse := layer.sparse(); sparse != nil {
+		rows := []int{}
+		for _, param := range params {
+			if *param.Gradient != 0.0 && (len(rows) == 0 || rows[len(rows)-1] != param.Unit) {
+				rows = append(rows, param.Unit)
+			}
+		}
+		sparse.SetRows(layer, rows)
+	}
+}
+
+// params of all layers in the order of Params
+func (neural *Neural) params() []Param {
+	params := []Param{}
+	for i := 0; i < neural.MaxLayers; i++ {
+		params = append(params, neural.Layers[i].params(nil)...)
+	}
+	return params
+}
+
+// NumParams is the amount of weights and biases of all layers
+func (neural *Neural) NumParams() int {
+	return len(neural.params())
+}
+
+// Params returns a copy of the weights and biases of all layers
+func (neural *Neural) Params() []float64 {
+	params := neural.params()
+	values := make([]float64, len(params))
+	for p, param := range params {
+		values[p] = *param.Value
+	}
+	return values
+}
+
+// SetParams replaces the weights and biases of all layers
+func (neural *Neural) SetParams(values []float64) error {
+	params := neural.params()
+	if len(values) != len(params) {
+		return fmt.Errorf("neural has %v params but got %v", len(params), len(values))
+	}
+
+	for p, param := range params {
+		*param.Value = values[p]
+	}
+	return nil
+}
+
+// Grads returns a copy of the gradients of all layers
+func (neural *Neural) Grads() []float64 {
+	params := neural.params()
+	grads := make([]float64, len(params))
+	for p, param := range params {
+		grads[p] = *param.Gradient
+	}
+	return grads
+}
+
+// setGrads replaces the gradients of all layers
+func (neural *Neural) setGrads(grads []float64) {
+	offset := 0
+	for i := 0; i < neural.MaxLayers; i++ {
+		total := neural.Layers[i].NumParams()
+		neural.Layers[i].setGrads(grads[offset : offset+total])
+		offset += total
+	}
+}
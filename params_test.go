@@ -0,0 +1,97 @@
+package neural
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParamsOrder(t *testing.T) {
+	neural := NewNeural([]*Layer{{Inputs: 2, Units: 2}, {Units: 1}})
+	neural.Seed(1)
+	neural.Reset()
+
+	// layer by layer, neuron by neuron, the weights and then the bias
+	expected := []float64{}
+	for _, layer := range neural.Layers {
+		for _, neuron := range layer.Neurons {
+			expected = append(append(expected, neuron.Weights...), neuron.Bias)
+		}
+	}
+	if params := neural.Params(); !reflect.DeepEqual(params, expected) || neural.NumParams() != 9 {
+		t.Fatalf("params %v expected %v", params, expected)
+	}
+}
+
+func TestParamsRoundTrip(t *testing.T) {
+	layers := func() []*Layer {
+		return []*Layer{
+			{Type: "embedding", Inputs: 2, Vocabulary: 3, Dimensions: 2},
+			{Units: 3, Activation: "tanh"},
+			{Type: "layernorm"},
+			{Units: 1},
+		}
+	}
+	neural := NewNeural(layers())
+	neural.Seed(1)
+	neural.Reset()
+	neural.LearnRaw([]float64{0, 2}, []float64{1})
+
+	other := NewNeural(layers())
+	other.Seed(2)
+	other.Reset()
+
+	if err := other.SetParams(neural.Params()); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(other.Params(), neural.Params()) {
+		t.Fatal("params changed in the round trip")
+	}
+	if a, b := neural.ThinkRaw([]float64{1, 2}), other.ThinkRaw([]float64{1, 2}); !reflect.DeepEqual(a, b) {
+		t.Fatalf("outputs %v and %v", a, b)
+	}
+
+	// every layer also round trips its own params
+	for i, layer := range neural.Layers {
+		if err := other.Layers[i].SetParams(layer.Params()); err != nil || !reflect.DeepEqual(other.Layers[i].Params(), layer.Params()) {
+			t.Fatalf("layer %v didn't round trip its params (%v)", i, err)
+		}
+	}
+
+	if err := other.SetParams(neural.Params()[1:]); err == nil {
+		t.Fatal("expected an error for the wrong amount of params")
+	}
+	if err := other.Layers[1].SetParams([]float64{1}); err == nil {
+		t.Fatal("expected an error for the wrong amount of layer params")
+	}
+}
+
+func TestGradsRoundTrip(t *testing.T) {
+	neural := NewNeural([]*Layer{{Inputs: 2, Units: 3}, {Units: 2}})
+	neural.backward(neural.forward([]float64{0.5, -0.5}, true), []float64{1, 0})
+
+	grads := neural.Grads()
+	if len(grads) != neural.NumParams() {
+		t.Fatalf("%v gradients for %v params", len(grads), neural.NumParams())
+	}
+
+	neural.ZeroGrad()
+	neural.setGrads(grads)
+	if !reflect.DeepEqual(neural.Grads(), grads) {
+		t.Fatal("gradients changed in the round trip")
+	}
+}
+
+func TestEmbeddingParamsWithoutBias(t *testing.T) {
+	layer := NewNeural([]*Layer{{Type: "embedding", Inputs: 2, Vocabulary: 3, Dimensions: 2}}).Layers[0]
+
+	// only the vectors are params, in the order of the indices
+	if layer.NumParams() != 6 {
+		t.Fatalf("embedding has %v params", layer.NumParams())
+	}
+	if err := layer.SetParams([]float64{1, 2, 3, 4, 5, 6}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(layer.Neurons[1].Weights, []float64{3, 4}) || layer.Neurons[1].Bias != 0 {
+		t.Fatalf("vector %v and bias %v", layer.Neurons[1].Weights, layer.Neurons[1].Bias)
+	}
+}
@@ -0,0 +1,63 @@
+package neural
+
+import (
+	"crypto/rand"
+	"math/big"
+)
+
+// Random is a seedable source (splitmix64) so runs can be repeated and resumed
+// A nil *Random falls back to crypto/rand, it's not safe for concurrent use
+type Random struct {
+	State uint64 `json:"State"`
+}
+
+// NewRandom creates a random source based on a seed
+func NewRandom(seed int64) *Random {
+	return &Random{State: uint64(seed)}
+}
+
+// Uint64 returns the next pseudo random number
+func (random *Random) Uint64() uint64 {
+	random.State += 0x9e3779b97f4a7c15
+	z := random.State
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+// Float64 returns a number in [0, 1)
+func (random *Random) Float64() float64 {
+	if random == nil {
+		return cryptoRandomFloat()
+	}
+	return float64(random.Uint64()>>11) / (1 << 53)
+}
+
+// Range returns a number in [min, max)
+func (random *Random) Range(min float64, max float64) float64 {
+	return min + random.Float64()*(max-min)
+}
+
+// Intn returns a number in [0, max)
+func (random *Random) Intn(max int) int {
+	if random == nil {
+		return randomInt(int64(max))
+	}
+	return int(random.Uint64() % uint64(max))
+}
+
+func cryptoRandomFloat() float64 {
+	num, err := rand.Int(rand.Reader, big.NewInt(1e17))
+	if err != nil {
+		panic(err)
+	}
+	return float64(num.Int64()) / float64(1e17)
+}
+
+func randomInt(max int64) int {
+	num, err := rand.Int(rand.Reader, big.NewInt(max))
+	if err != nil {
+		panic(err)
+	}
+	return int(num.Int64())
+}
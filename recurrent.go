@@ -0,0 +1,281 @@
+package neural
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Recurrent layers keep a hidden state between the steps of a sequence (default activation is tanh)
+// Their neurons are grouped by gate and every neuron has weights for the inputs followed by the hidden state:
+// rnn has one gate (h = activation(W·[x, h])),
+// lstm has input, forget, cell and output gates (c = f*c + i*g, h = o*activation(c)),
+// gru has update, reset and candidate gates (n = activation(W·[x, r*h]), h = (1-z)*n + z*h)
+// Gates other than the cell and candidate always use sigmoid
+
+// recurrentStep is what a step of a recurrent layer keeps for the backpropagation through time
+type recurrentStep struct {
+	// Inputs followed by the previous hidden state
+	inputs []float64
+	// Inputs of the gru candidate (inputs followed by reset * previous hidden state)
+	candidate []float64
+	// Activations of every gate
+	gates    [][]float64
+	cell     []float64
+	prevCell []float64
+	hidden   []float64
+}
+
+// recurrentKind keeps the hidden state and cell, the last step and the errors of the state from the next step
+type recurrentKind struct {
+	NeuronKind
+	hidden      []float64
+	cell        []float64
+	last        *recurrentStep
+	carryHidden []float64
+	carryCell   []float64
+}
+
+func (layer *Layer) isRecurrent() bool {
+	return layer.Type == "rnn" || layer.Type == "lstm" || layer.Type == "gru"
+}
+
+// gates is the amount of neurons per unit
+func (layer *Layer) gates() int {
+	switch layer.Type {
+	case "lstm":
+		return 4
+	case "gru":
+		return 3
+	}
+	return 1
+}
+
+// New creates the neurons of every gate (the lstm forget gate starts with bias 1 to remember)
+func (*recurrentKind) New(layer *Layer) {
+	if layer.Units == 0 {
+		panic("need units in recurrent layers")
+	}
+	if layer.Activation == "" {
+		layer.Activation = "tanh"
+	}
+
+	layer.Neurons = make([]*Neuron, layer.Units*layer.gates())
+	for i := range layer.Neurons {
+		layer.Neurons[i] = NewNeuron(layer, layer.Inputs+layer.Units)
+	}
+
+	if layer.Type == "lstm" {
+		for _, neuron := range layer.Neurons[layer.Units : 2*layer.Units] {
+			neuron.Bias = 1.0
+		}
+	}
+
+	layer.ResetState()
+}
+
+func (kind *recurrentKind) Import(layer *Layer, data json.RawMessage) error {
+	if len(layer.Neurons) == 0 || len(layer.Neurons)%layer.gates() != 0 {
+		return fmt.Errorf("need the neurons of every gate in %v layers", layer.Type)
+	}
+	kind.NeuronKind.Import(layer, data)
+	layer.Units = len(layer.Neurons) / layer.gates()
+	layer.Inputs = len(layer.Neurons[0].Weights) - layer.Units
+	layer.ResetState()
+	return nil
+}
+
+func (kind *recurrentKind) Reset(layer *Layer) {
+	kind.NeuronKind.Reset(layer)
+	layer.ResetState()
+}
+
+func (kind *recurrentKind) State(layer *Layer) interface{} {
+	return kind.last
+}
+
+func (kind *recurrentKind) SetState(layer *Layer, state interface{}) {
+	kind.last = state.(*recurrentStep)
+}
+
+// recurrent is the kind of a recurrent layer (nil for other layers)
+func (layer *Layer) recurrent() *recurrentKind {
+	recurrent, _ := layer.kind().(*recurrentKind)
+	return recurrent
+}
+
+// ResetState clears the hidden state (and cell of lstm) of a recurrent layer
+func (layer *Layer) ResetState() {
+	if kind := layer.recurrent(); kind != nil {
+		kind.hidden = make([]float64, layer.Units)
+		kind.cell = make([]float64, layer.Units)
+		kind.last = nil
+	}
+}
+
+// Forward process a step of the sequence and keeps the new state
+func (kind *recurrentKind) Forward(layer *Layer, inputs []float64, outs []float64, training bool) {
+	units := layer.Units
+	step := &recurrentStep{
+		inputs: append(append(make([]float64, 0, layer.Inputs+units), inputs[:layer.Inputs]...), kind.hidden...),
+		gates:  make([][]float64, layer.gates()),
+	}
+
+	// gate computes the activations of the neurons of a gate
+	gate := func(g int, inputs []float64, forward ForwardFn) []float64 {
+		activations := make([]float64, units)
+		for j, neuron := range layer.Neurons[g*units : (g+1)*units] {
+			sum := neuron.Bias
+			for w, input := range inputs {
+				sum += input * neuron.Weights[w]
+			}
+			activations[j] = forward(sum)
+		}
+		step.gates[g] = activations
+		return activations
+	}
+
+	hidden := make([]float64, units)
+
+	switch layer.Type {
+	case "rnn":
+		copy(hidden, gate(0, step.inputs, layer.Forward))
+	case "lstm":
+		i, f := gate(0, step.inputs, SigmoidForward), gate(1, step.inputs, SigmoidForward)
+		g, o := gate(2, step.inputs, layer.Forward), gate(3, step.inputs, SigmoidForward)
+
+		step.prevCell = kind.cell
+		step.cell = make([]float64, units)
+		for j := 0; j < units; j++ {
+			step.cell[j] = f[j]*step.prevCell[j] + i[j]*g[j]
+			hidden[j] = o[j] * layer.Forward(step.cell[j])
+		}
+		kind.cell = step.cell
+	case "gru":
+		z, r := gate(0, step.inputs, SigmoidForward), gate(1, step.inputs, SigmoidForward)
+
+		step.candidate = append(make([]float64, 0, layer.Inputs+units), inputs[:layer.Inputs]...)
+		for j := 0; j < units; j++ {
+			step.candidate = append(step.candidate, r[j]*kind.hidden[j])
+		}
+
+		n := gate(2, step.candidate, layer.Forward)
+		for j := 0; j < units; j++ {
+			hidden[j] = (1.0-z[j])*n[j] + z[j]*kind.hidden[j]
+		}
+	}
+
+	step.hidden = hidden
+	kind.hidden = hidden
+	kind.last = step
+	copy(outs, hidden)
+}
+
+// Backward adds the gradients of the last step (plus the errors coming from the next step)
+// and returns the errors of the inputs, the errors of the previous state are kept for the previous step
+func (kind *recurrentKind) Backward(layer *Layer, errors []float64) []float64 {
+	units, step := layer.Units, kind.last
+
+	// errors of the state from the next step, only while learning a sequence
+	carryHidden, carryCell := kind.carryHidden, kind.carryCell
+	if carryHidden == nil {
+		carryHidden, carryCell = make([]float64, units), make([]float64, units)
+	}
+
+	hidden := make([]float64, units)
+	for j := range hidden {
+		hidden[j] = errors[j] + carryHidden[j]
+	}
+
+	// inputErrors of the inputs followed by the previous hidden state
+	inputErrors := make([]float64, layer.Inputs+units)
+	previous := step.inputs[layer.Inputs:]
+
+	// backGate adds the gradients of the neurons of a gate and propagates their deltas to the errors of its inputs
+	backGate := func(g int, deltas []float64, inputs []float64, errors []float64) {
+		for j, neuron := range layer.Neurons[g*units : (g+1)*units] {
+			for w, input := range inputs {
+				errors[w] += neuron.Weights[w] * deltas[j]
+				neuron.Gradients[w] -= input * deltas[j]
+			}
+			neuron.Gradients[neuron.MaxInputs] -= deltas[j]
+		}
+	}
+
+	switch layer.Type {
+	case "rnn":
+		deltas := make([]float64, units)
+		for j := range deltas {
+			deltas[j] = layer.Backward(step.gates[0][j]) * hidden[j]
+		}
+		backGate(0, deltas, step.inputs, inputErrors)
+	case "lstm":
+		i, f, g, o := step.gates[0], step.gates[1], step.gates[2], step.gates[3]
+		deltas := [][]float64{make([]float64, units), make([]float64, units), make([]float64, units), make([]float64, units)}
+
+		for j := 0; j < units; j++ {
+			activation := layer.Forward(step.cell[j])
+			cell := hidden[j]*o[j]*layer.Backward(activation) + carryCell[j]
+
+			deltas[0][j] = SigmoidBackward(i[j]) * cell * g[j]
+			deltas[1][j] = SigmoidBackward(f[j]) * cell * step.prevCell[j]
+			deltas[2][j] = layer.Backward(g[j]) * cell * i[j]
+			deltas[3][j] = SigmoidBackward(o[j]) * hidden[j] * activation
+			carryCell[j] = cell * f[j]
+		}
+
+		for gate := range deltas {
+			backGate(gate, deltas[gate], step.inputs, inputErrors)
+		}
+	case "gru":
+		z, r, n := step.gates[0], step.gates[1], step.gates[2]
+		deltas := [][]float64{make([]float64, units), make([]float64, units), make([]float64, units)}
+
+		for j := 0; j < units; j++ {
+			deltas[0][j] = SigmoidBackward(z[j]) * hidden[j] * (previous[j] - n[j])
+			deltas[2][j] = layer.Backward(n[j]) * hidden[j] * (1.0 - z[j])
+		}
+
+		candidateErrors := make([]float64, layer.Inputs+units)
+		backGate(2, deltas[2], step.candidate, candidateErrors)
+
+		for j := 0; j < units; j++ {
+			deltas[1][j] = SigmoidBackward(r[j]) * candidateErrors[layer.Inputs+j] * previous[j]
+		}
+
+		backGate(0, deltas[0], step.inputs, inputErrors)
+		backGate(1, deltas[1], step.inputs, inputErrors)
+
+		for w := 0; w < layer.Inputs; w++ {
+			inputErrors[w] += candidateErrors[w]
+		}
+		for j := 0; j < units; j++ {
+			inputErrors[layer.Inputs+j] += hidden[j]*z[j] + candidateErrors[layer.Inputs+j]*r[j]
+		}
+	}
+
+	copy(carryHidden, inputErrors[layer.Inputs:])
+	return inputErrors[:layer.Inputs]
+}
+
+// Resize changes the amount of inputs keeping the weights of the inputs that still fit and the hidden state
+func (*recurrentKind) Resize(layer *Layer, inputs int) {
+	for _, neuron := range layer.Neurons {
+		resized := NewNeuron(layer, inputs+layer.Units)
+		copy(resized.Weights, neuron.Weights[:minInt(inputs, layer.Inputs)])
+		copy(resized.Weights[inputs:], neuron.Weights[layer.Inputs:])
+		neuron.Weights = resized.Weights
+		neuron.MaxInputs = resized.MaxInputs
+		neuron.Momentums = resized.Momentums
+		neuron.Gradients = resized.Gradients
+		neuron.Inputs = resized.Inputs
+	}
+
+	layer.Inputs = inputs
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,102 @@
+package neural
+
+import (
+	"reflect"
+	"testing"
+)
+
+// sequence of steps with 2 inputs
+var sequence = [][]float64{{0.5, -0.3}, {0.1, 0.8}, {-0.6, 0.2}, {0.4, 0.4}, {-0.2, -0.7}}
+
+// recurrentNeural stacks a recurrent layer of every type on another one and a dense output
+func recurrentNeural(kind string) *Neural {
+	neural := NewNeural([]*Layer{
+		{Inputs: 2, Units: 3, Type: kind},
+		{Units: 2, Type: kind},
+		{Units: 2},
+	})
+	neural.Seed(1)
+	neural.Reset()
+	return neural
+}
+
+// roundTrip exports and imports a neural
+func roundTrip(t *testing.T, neural *Neural) *Neural {
+	t.Helper()
+
+	encoded, err := neural.Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := NewNeural([]*Layer{})
+	if err := imported.Import(encoded); err != nil {
+		t.Fatal(err)
+	}
+	return imported
+}
+
+// checkSequenceGradients fails if GradCheckSequence finds a layer with wrong gradients
+func checkSequenceGradients(t *testing.T, neural *Neural, inputs [][]float64, outputs [][]float64) {
+	t.Helper()
+
+	errors := GradCheckSequence(neural, inputs, outputs)
+	for i, err := range errors {
+		if err > gradTolerance {
+			t.Fatalf("layer %v (%v) has a relative error of %.2e, all layers %.2e", i, neural.Layers[i].Type, err, errors)
+		}
+	}
+}
+
+func TestGradCheckRecurrent(t *testing.T) {
+	many := [][]float64{{0.9, 0.1}, {0.2, 0.7}, {0.5, 0.5}, {0.1, 0.3}, {0.8, 0.6}}
+
+	for _, kind := range []string{"rnn", "lstm", "gru"} {
+		t.Run(kind+"/many-to-many", func(t *testing.T) {
+			checkSequenceGradients(t, recurrentNeural(kind), sequence, many)
+		})
+		t.Run(kind+"/many-to-one", func(t *testing.T) {
+			checkSequenceGradients(t, recurrentNeural(kind), sequence, many[:1])
+		})
+		// a truncated chunk starts from the state of the previous one
+		t.Run(kind+"/chunk", func(t *testing.T) {
+			neural := recurrentNeural(kind)
+			neural.ThinkSequenceRaw(sequence[:3])
+			checkSequenceGradients(t, neural, sequence[3:], many[3:])
+		})
+	}
+}
+
+func TestTruncateIsStatefulChunks(t *testing.T) {
+	targets := [][]float64{{1, 0}, {0, 1}, {1, 1}, {0, 0}}
+
+	for _, kind := range []string{"rnn", "lstm", "gru"} {
+		truncated := recurrentNeural(kind)
+		truncated.Truncate = 2
+		truncated.LearnSequenceRaw(sequence[:4], targets)
+
+		// learning the chunks as sequences of stateful layers is the same
+		chunks := recurrentNeural(kind)
+		chunks.Layers[0].Stateful, chunks.Layers[1].Stateful = true, true
+		chunks.LearnSequenceRaw(sequence[:2], targets[:2])
+		chunks.LearnSequenceRaw(sequence[2:4], targets[2:])
+
+		if !reflect.DeepEqual(truncated.Params(), chunks.Params()) {
+			t.Fatalf("%v learned different params with truncation", kind)
+		}
+	}
+}
+
+func TestRecurrentRoundTrip(t *testing.T) {
+	for _, kind := range []string{"rnn", "lstm", "gru"} {
+		neural := recurrentNeural(kind)
+		neural.LearnSequenceRaw(sequence, [][]float64{{1, 0}})
+		imported := roundTrip(t, neural)
+
+		if !reflect.DeepEqual(imported.Params(), neural.Params()) {
+			t.Fatalf("%v params changed", kind)
+		}
+		if !reflect.DeepEqual(imported.ThinkSequenceRaw(sequence), neural.ThinkSequenceRaw(sequence)) {
+			t.Fatalf("%v thinks different after import", kind)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package neural
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRegularizedUpdate(t *testing.T) {
+	tests := []struct {
+		name   string
+		layer  Layer
+		weight func(w float64) float64
+		bias   func(b float64) float64
+	}{
+		// without gradients the update is only the regularization, rate is 0.1 and the momentums start at zero
+		{"l1", Layer{L1: 0.5}, func(w float64) float64 { return w - 0.1*0.5*sign(w) }, nil},
+		{"l2", Layer{L2: 0.5}, func(w float64) float64 { return w - 0.1*0.5*w }, nil},
+		{"weight decay", Layer{WeightDecay: 0.5}, func(w float64) float64 { return w - 0.1*0.5*w }, nil},
+		{"elastic-net", Layer{L1: 0.2, L2: 0.3}, func(w float64) float64 { return w - 0.1*(0.2*sign(w)+0.3*w) }, nil},
+		{"bias", Layer{L2: 0.5, WeightDecay: 0.5, RegularizeBias: true}, func(w float64) float64 { return w - 0.1*0.5*w - 0.1*0.5*w }, func(b float64) float64 { return b - 0.1*0.5*b - 0.1*0.5*b }},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			layer := test.layer
+			layer.Inputs, layer.Units, layer.Rate = 2, 2, 0.1
+			neural := NewNeural([]*Layer{&layer})
+			neural.Seed(1)
+			neural.Reset()
+
+			before := neural.Layers[0].Neurons[0]
+			weights, bias := append([]float64{}, before.Weights...), before.Bias
+			if err := neural.update(); err != nil {
+				t.Fatal(err)
+			}
+
+			neuron := neural.Layers[0].Neurons[0]
+			for w, weight := range weights {
+				if math.Abs(neuron.Weights[w]-test.weight(weight)) > 1e-12 {
+					t.Fatalf("weight %v is %v expected %v", w, neuron.Weights[w], test.weight(weight))
+				}
+			}
+			expected := bias
+			if test.bias != nil {
+				expected = test.bias(bias)
+			}
+			if math.Abs(neuron.Bias-expected) > 1e-12 {
+				t.Fatalf("bias is %v expected %v", neuron.Bias, expected)
+			}
+		})
+	}
+}
+
+func TestPenalty(t *testing.T) {
+	neural := NewNeural([]*Layer{{Inputs: 2, Units: 2, L1: 0.1, L2: 0.2}, {Units: 1}})
+	neural.Seed(1)
+	neural.Reset()
+	dataset := [][][]float64{{{0, 1}, {1}}, {{1, 0}, {0}}}
+
+	unregularized := neural.Clone()
+	unregularized.Layers[0].L1, unregularized.Layers[0].L2 = 0, 0
+
+	loss := neural.LearnsRaw(dataset)
+
+	// the penalty of the weights after learning (not the biases) is reported apart from the loss
+	expected := 0.0
+	for _, neuron := range neural.Layers[0].Neurons {
+		for _, weight := range neuron.Weights {
+			expected += 0.1*math.Abs(weight) + 0.5*0.2*weight*weight
+		}
+	}
+	if math.Abs(neural.Penalty-expected) > 1e-12 {
+		t.Fatalf("penalty %v expected %v", neural.Penalty, expected)
+	}
+	if unregularized.LearnsRaw(dataset); unregularized.Penalty != 0 || math.Abs(loss-unregularized.Loss) > 1e-3 {
+		t.Fatalf("loss %v includes the penalty (%v without regularization)", loss, unregularized.Loss)
+	}
+}
+
+func TestNormGainsNotRegularized(t *testing.T) {
+	neural := NewNeural([]*Layer{
+		{Type: "embedding", Inputs: 2, Vocabulary: 3, Dimensions: 2},
+		{Type: "transformer", Hidden: 2},
+		{Units: 2},
+		{Type: "batchnorm"},
+		{Type: "layernorm"},
+	})
+	neural.Seed(1)
+	neural.Reset()
+	for _, layer := range neural.Layers {
+		layer.L1, layer.L2, layer.WeightDecay = 0.1, 0.1, 0.1
+	}
+	neural.Layers[0].Frozen = true
+
+	params := neural.Layers[1].params(nil)
+	values := neural.Params()
+	if err := neural.update(); err != nil {
+		t.Fatal(err)
+	}
+
+	// gamma and beta keep their values, the rest of the weights shrink
+	for _, i := range []int{3, 4} {
+		for _, neuron := range neural.Layers[i].Neurons {
+			if neuron.Weights[0] != 1 || neuron.Bias != 0 {
+				t.Fatalf("layer %v changed gamma %v or beta %v", i, neuron.Weights[0], neuron.Bias)
+			}
+		}
+		if neural.Layers[i].penalty() != 0 {
+			t.Fatalf("layer %v has a penalty", i)
+		}
+	}
+	offset := neural.Layers[0].NumParams()
+	for p, param := range params {
+		changed := *param.Value != values[offset+p]
+		if changed == param.Bias {
+			t.Fatalf("transformer param %v (unit %v) changed %v", p, param.Unit, changed)
+		}
+	}
+}
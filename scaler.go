@@ -0,0 +1,326 @@
+package neural
+
+import (
+	"math"
+	"sort"
+)
+
+// Scaler converts an arbitrary value to raw and vice versa
+// Types are minmax, clip (minmax that clamps values outside the range), log, zscore and robust
+type Scaler struct {
+	Type string `json:"Type"`
+	// Range of values for minmax, clip and log
+	Min float64 `json:"Min,omitempty"`
+	Max float64 `json:"Max,omitempty"`
+	// Mean and standard deviation for zscore, median and interquartile range for robust
+	Center float64 `json:"Center,omitempty"`
+	Scale  float64 `json:"Scale,omitempty"`
+	// Raw range for minmax, clip and log (activation range of the layer)
+	Raw []float64 `json:"Raw,omitempty"`
+}
+
+// NewScaler fits a scaler to values (missing values are ignored), raw is the target range [min, max]
+func NewScaler(kind string, values []float64, raw []float64) Scaler {
+	sorted := []float64{}
+	for _, value := range values {
+		if !math.IsNaN(value) {
+			sorted = append(sorted, value)
+		}
+	}
+	sort.Float64s(sorted)
+
+	scaler := Scaler{Type: kind, Scale: 1.0}
+	if kind != "minmax" && kind != "clip" && kind != "log" && kind != "zscore" && kind != "robust" {
+		panic("need a valid scaler type")
+	}
+	if len(sorted) == 0 {
+		scaler.Raw = raw
+		return scaler
+	}
+
+	switch kind {
+	case "minmax", "clip", "log":
+		scaler.Min, scaler.Max = sorted[0], sorted[len(sorted)-1]
+		scaler.Raw = raw
+	case "zscore":
+		mean, variance := meanVariance(sorted)
+		scaler.Center, scaler.Scale = mean, math.Sqrt(variance)
+	case "robust":
+		scaler.Center = percentile(sorted, 0.5)
+		scaler.Scale = percentile(sorted, 0.75) - percentile(sorted, 0.25)
+	}
+
+	if scaler.Scale == 0.0 {
+		scaler.Scale = 1.0
+	}
+
+	return scaler
+}
+
+// ToRaw converts an arbitrary value to raw
+func (scaler Scaler) ToRaw(value float64) float64 {
+	switch scaler.Type {
+	case "zscore", "robust":
+		return (value - scaler.Center) / scaler.Scale
+	case "clip":
+		value = math.Max(scaler.Min, math.Min(scaler.Max, value))
+	}
+
+	if scaler.Max == scaler.Min {
+		return scaler.Raw[0]
+	}
+	if scaler.Type == "log" {
+		return rangeToRange(math.Log1p(math.Max(value-scaler.Min, 0.0)), 0.0, scaler.logMax(), scaler.Raw[0], scaler.Raw[1])
+	}
+	return rangeToRange(value, scaler.Min, scaler.Max, scaler.Raw[0], scaler.Raw[1])
+}
+
+// FromRaw converts a raw value to arbitrary
+func (scaler Scaler) FromRaw(raw float64) float64 {
+	switch scaler.Type {
+	case "zscore", "robust":
+		return raw*scaler.Scale + scaler.Center
+	case "log":
+		return math.Expm1(rangeToRange(raw, scaler.Raw[0], scaler.Raw[1], 0.0, scaler.logMax())) + scaler.Min
+	}
+
+	if scaler.Max == scaler.Min {
+		return scaler.Min
+	}
+	return rangeToRange(raw, scaler.Raw[0], scaler.Raw[1], scaler.Min, scaler.Max)
+}
+
+func (scaler Scaler) logMax() float64 {
+	return math.Log1p(scaler.Max - scaler.Min)
+}
+
+// FitRanges sets the input and output ranges (min/max) from the selected columns of a dataset
+// A constant column is converted to the minimum of the activation range
+func (neural *Neural) FitRanges(dataset *Dataset) {
+	fitted := []valueRanges{neural.inputRanges(), neural.outputRanges()}
+
+	for l, columns := range [][]string{dataset.inputColumns(), dataset.Targets} {
+		layer := neural.Layers[0]
+		if l == 1 {
+			layer = neural.Layers[neural.MaxLayers-1]
+		}
+
+		fitted[l].Range = [][]float64{}
+
+		activation := selectActivation(layer.Activation)
+		if len(activation.Ranges) == 0 {
+			continue
+		}
+
+		for _, column := range columns {
+			scaler := NewScaler("minmax", dataset.Column(column), nil)
+			fitted[l].Range = append(fitted[l].Range, []float64{scaler.Min, scaler.Max, activation.Ranges[0], activation.Ranges[1]})
+		}
+	}
+
+	neural.setRanges(fitted[0], fitted[1])
+}
+
+// FitScalers sets a type of scaler for inputs and another for outputs from a dataset (empty type skips it)
+// Scalers are exported with the neural and have priority over ranges
+func (neural *Neural) FitScalers(dataset *Dataset, inputs string, outputs string) {
+	fitted := []valueRanges{neural.inputRanges(), neural.outputRanges()}
+
+	for l, columns := range [][]string{dataset.inputColumns(), dataset.Targets} {
+		kind := inputs
+		layer := neural.Layers[0]
+		if l == 1 {
+			kind = outputs
+			layer = neural.Layers[neural.MaxLayers-1]
+		}
+
+		if kind == "" {
+			continue
+		}
+
+		raw := layer.rawRange()
+		fitted[l].Scalers = make([]Scaler, len(columns))
+		for i, column := range columns {
+			fitted[l].Scalers[i] = NewScaler(kind, dataset.Column(column), raw)
+		}
+	}
+
+	neural.setRanges(fitted[0], fitted[1])
+}
+
+// rawRange is the activation range of the layer (or [0, 1] if the activation is unbounded)
+func (layer *Layer) rawRange() []float64 {
+	ranges := selectActivation(layer.Activation).Ranges
+	if len(ranges) == 0 {
+		return []float64{0.0, 1.0}
+	}
+	return []float64{ranges[0], ranges[1]}
+}
+
+// valueRanges are the ranges or scalers of the inputs or outputs of a neural (scalers have priority over ranges)
+type valueRanges struct {
+	Range   [][]float64
+	Scalers []Scaler
+}
+
+func (values valueRanges) empty() bool {
+	return len(values.Range) == 0 && len(values.Scalers) == 0
+}
+
+func (values valueRanges) toRaw(arbitrary []float64) []float64 {
+	if total := len(values.Scalers); total > 0 {
+		raw := make([]float64, total)
+		for i, scaler := range values.Scalers {
+			raw[i] = scaler.ToRaw(arbitrary[i])
+		}
+		return raw
+	}
+
+	total := len(values.Range)
+	if total == 0 {
+		return arbitrary
+	}
+
+	raw := make([]float64, total)
+	for i, ranges := range values.Range {
+		raw[i] = ranges[2]
+		if ranges[0] != ranges[1] {
+			raw[i] = rangeToRange(arbitrary[i], ranges[0], ranges[1], ranges[2], ranges[3])
+		}
+	}
+	return raw
+}
+
+func (values valueRanges) fromRaw(raw []float64) []float64 {
+	if total := len(values.Scalers); total > 0 {
+		arbitrary := make([]float64, total)
+		for i, scaler := range values.Scalers {
+			arbitrary[i] = scaler.FromRaw(raw[i])
+		}
+		return arbitrary
+	}
+
+	total := len(values.Range)
+	if total == 0 {
+		return raw
+	}
+
+	arbitrary := make([]float64, total)
+	for i, ranges := range values.Range {
+		arbitrary[i] = rangeToRange(raw[i], ranges[2], ranges[3], ranges[0], ranges[1])
+		if ranges[0] == ranges[1] {
+			arbitrary[i] = ranges[0]
+		}
+	}
+	return arbitrary
+}
+
+// adapt gives the ranges and scalers to a layer: their raw range becomes its activation range,
+// unbounded activations keep the previous one so the conversion doesn't change (like a new identity head)
+func (values valueRanges) adapt(layer *Layer) valueRanges {
+	ranges := selectActivation(layer.Activation).Ranges
+	adapted := valueRanges{[][]float64{}, nil}
+
+	for _, scaler := range values.Scalers {
+		if len(scaler.Raw) > 0 && len(ranges) > 0 {
+			scaler.Raw = layer.rawRange()
+		}
+		adapted.Scalers = append(adapted.Scalers, scaler)
+	}
+	for _, r := range values.Range {
+		if len(ranges) > 0 {
+			r = []float64{r[0], r[1], ranges[0], ranges[1]}
+		}
+		adapted.Range = append(adapted.Range, append([]float64{}, r...))
+	}
+	return adapted
+}
+
+// scalers of the values with a minmax scaler per range (or an identity if there are none)
+func (values valueRanges) scalers(total int) []Scaler {
+	if len(values.Scalers) > 0 {
+		return values.Scalers
+	}
+
+	scalers := make([]Scaler, total)
+	for i := range scalers {
+		scalers[i] = Scaler{Type: "zscore", Scale: 1.0}
+		if i < len(values.Range) {
+			r := values.Range[i]
+			scalers[i] = Scaler{Type: "minmax", Min: r[0], Max: r[1], Raw: []float64{r[2], r[3]}}
+		}
+	}
+	return scalers
+}
+
+// ranges of the values (or an identity range of the layer if there are none)
+func (values valueRanges) ranges(layer *Layer, total int) [][]float64 {
+	if len(values.Range) > 0 {
+		return values.Range
+	}
+
+	raw := layer.rawRange()
+	ranges := make([][]float64, total)
+	for i := range ranges {
+		ranges[i] = []float64{raw[0], raw[1], raw[0], raw[1]}
+	}
+	return ranges
+}
+
+// rangeSplit is where the ranges (or scalers) of inputs end in the first layer and where the ones of outputs start in the last one
+// A single layer keeps the ones of its inputs followed by the ones of its outputs (or uses the same ones for both)
+func (neural *Neural) rangeSplit(total int) (int, int) {
+	if layer := neural.Layers[0]; neural.MaxLayers == 1 && total > 0 && total == layer.Inputs+layer.Units {
+		return layer.Inputs, layer.Inputs
+	}
+	return total, 0
+}
+
+// inputRanges are the ranges and scalers of the inputs (in the first layer)
+func (neural *Neural) inputRanges() valueRanges {
+	layer := neural.Layers[0]
+	ranges, _ := neural.rangeSplit(len(layer.Range))
+	scalers, _ := neural.rangeSplit(len(layer.Scalers))
+	return valueRanges{layer.Range[:ranges], layer.Scalers[:scalers]}
+}
+
+// outputRanges are the ranges and scalers of the outputs (in the last layer)
+func (neural *Neural) outputRanges() valueRanges {
+	layer := neural.Layers[neural.MaxLayers-1]
+	_, ranges := neural.rangeSplit(len(layer.Range))
+	_, scalers := neural.rangeSplit(len(layer.Scalers))
+	return valueRanges{layer.Range[ranges:], layer.Scalers[scalers:]}
+}
+
+// setRanges gives the ranges and scalers of inputs to the first layer and the ones of outputs to the last layer,
+// hidden layers don't keep any
+func (neural *Neural) setRanges(inputs valueRanges, outputs valueRanges) {
+	for i := 0; i < neural.MaxLayers; i++ {
+		neural.Layers[i].Range = [][]float64{}
+		neural.Layers[i].Scalers = nil
+	}
+
+	first, last := neural.Layers[0], neural.Layers[neural.MaxLayers-1]
+	inputs, outputs = inputs.adapt(first), outputs.adapt(last)
+
+	if neural.MaxLayers > 1 {
+		first.Range, first.Scalers = inputs.Range, inputs.Scalers
+		last.Range, last.Scalers = outputs.Range, outputs.Scalers
+		return
+	}
+
+	// a single layer needs both parts, the missing one is an identity
+	if len(inputs.Scalers) > 0 || len(outputs.Scalers) > 0 {
+		first.Scalers = append(append([]Scaler{}, inputs.scalers(first.Inputs)...), outputs.scalers(first.Units)...)
+	} else if !inputs.empty() || !outputs.empty() {
+		first.Range = append(append([][]float64{}, inputs.ranges(first, first.Inputs)...), outputs.ranges(first, first.Units)...)
+	}
+}
+
+// percentile of sorted values with linear interpolation
+func percentile(sorted []float64, p float64) float64 {
+	position := p * float64(len(sorted)-1)
+	lower := int(math.Floor(position))
+	upper := int(math.Ceil(position))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(position-float64(lower))
+}
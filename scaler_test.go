@@ -0,0 +1,102 @@
+package neural
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFitRangesConstantColumn(t *testing.T) {
+	// the second input is the same in every sample
+	dataset := NewDataset([][][]float64{{{1, 0.5}, {10}}, {{3, 0.5}, {20}}, {{2, 0.5}, {30}}})
+	neural := NewNeural([]*Layer{{Inputs: 2, Units: 2}, {Units: 1}})
+	neural.Seed(1)
+	neural.Reset()
+	neural.FitRanges(dataset)
+
+	if raw := neural.InputValuesToRaw([]float64{2, 0.5}); raw[0] != 0.5 || raw[1] != 0 {
+		t.Fatalf("inputs are converted to %v", raw)
+	}
+
+	neural.Learns(dataset.Samples())
+	if err := neural.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if outputs := neural.Think([]float64{2, 0.5}); math.IsNaN(outputs[0]) || outputs[0] < 10 || outputs[0] > 30 {
+		t.Fatalf("outputs %v", outputs)
+	}
+
+	// a constant target is converted back to its value
+	constant := NewNeural([]*Layer{{Inputs: 2, Units: 1}})
+	constant.FitRanges(NewDataset([][][]float64{{{1, 2}, {7}}, {{3, 4}, {7}}}))
+	if outputs := constant.OutputValuesFromRaw([]float64{0.3}); outputs[0] != 7 {
+		t.Fatalf("output %v instead of 7", outputs[0])
+	}
+}
+
+func TestFitRangesSingleLayer(t *testing.T) {
+	dataset := NewDataset([][][]float64{{{0, 10}, {0, 100, 50}}, {{1, 30}, {1, 300, 60}}})
+	neural := NewNeural([]*Layer{{Inputs: 2, Units: 3}})
+	neural.FitRanges(dataset)
+
+	if raw := neural.InputValuesToRaw([]float64{0.5, 20}); len(raw) != 2 || raw[0] != 0.5 || raw[1] != 0.5 {
+		t.Fatalf("inputs are converted to %v", raw)
+	}
+	if outputs := neural.OutputValuesFromRaw([]float64{0.5, 0.5, 0.5}); len(outputs) != 3 || outputs[0] != 0.5 || outputs[1] != 200 || outputs[2] != 55 {
+		t.Fatalf("outputs are converted to %v", outputs)
+	}
+}
+
+func TestFitScalers(t *testing.T) {
+	dataset := NewDataset([][][]float64{{{1, 10}, {0}}, {{3, 20}, {50}}, {{5, 60}, {100}}})
+
+	t.Run("types", func(t *testing.T) {
+		neural := NewNeural([]*Layer{{Inputs: 2, Units: 2, Activation: "tanh"}, {Units: 1}})
+		neural.FitRanges(dataset)
+		neural.FitScalers(dataset, "zscore", "minmax")
+
+		inputs := neural.Layers[0].Scalers
+		if len(inputs) != 2 || inputs[0].Type != "zscore" || inputs[0].Center != 3 || len(neural.Layers[1].Scalers) != 1 {
+			t.Fatalf("scalers %v %v", inputs, neural.Layers[1].Scalers)
+		}
+
+		// scalers have priority over ranges
+		if raw := neural.InputValuesToRaw([]float64{3, 30}); raw[0] != 0 || raw[1] != 0 {
+			t.Fatalf("inputs are converted to %v", raw)
+		}
+		if outputs := neural.OutputValuesFromRaw([]float64{0.25}); outputs[0] != 25 {
+			t.Fatalf("outputs are converted to %v", outputs)
+		}
+		if raw := neural.OutputValuesToRaw([]float64{50}); raw[0] != 0.5 {
+			t.Fatalf("outputs are converted to raw %v", raw)
+		}
+	})
+
+	t.Run("skipped", func(t *testing.T) {
+		neural := NewNeural([]*Layer{{Inputs: 2, Units: 2}, {Units: 1}})
+		neural.FitScalers(dataset, "", "robust")
+
+		if len(neural.Layers[0].Scalers) != 0 || len(neural.Layers[1].Scalers) != 1 || neural.Layers[1].Scalers[0].Type != "robust" {
+			t.Fatalf("scalers %v %v", neural.Layers[0].Scalers, neural.Layers[1].Scalers)
+		}
+	})
+
+	t.Run("single layer", func(t *testing.T) {
+		neural := NewNeural([]*Layer{{Inputs: 2, Units: 1}})
+		neural.FitScalers(dataset, "minmax", "")
+
+		if raw := neural.InputValuesToRaw([]float64{3, 35}); raw[0] != 0.5 || raw[1] != 0.5 {
+			t.Fatalf("inputs are converted to %v", raw)
+		}
+		if outputs := neural.OutputValuesFromRaw([]float64{0.3}); outputs[0] != 0.3 {
+			t.Fatalf("outputs without scalers are converted to %v", outputs)
+		}
+
+		neural.FitScalers(dataset, "", "minmax")
+		if raw := neural.InputValuesToRaw([]float64{3, 35}); raw[0] != 0.5 || raw[1] != 0.5 {
+			t.Fatalf("inputs changed to %v after fitting the outputs", raw)
+		}
+		if outputs := neural.OutputValuesFromRaw([]float64{0.3}); outputs[0] != 30 {
+			t.Fatalf("outputs are converted to %v", outputs)
+		}
+	})
+}
@@ -0,0 +1,305 @@
+package neural
+
+import (
+	"math"
+	"reflect"
+)
+
+// Scheduler changes the rate of all layers while learning
+// It returns a factor that multiplies the own rate of every layer (so per-layer differences are kept)
+type Scheduler interface {
+	// Step is called before every learned sample with the amount of previous steps
+	Step(step int) float64
+	// Epoch is called after every Learns or LearnsRaw with the amount of epochs and the loss (validation if set)
+	Epoch(epoch int, loss float64) float64
+}
+
+// SchedulerState is a scheduler whose state can be saved to resume the training (Checkpoint uses it)
+type SchedulerState interface {
+	State() []float64
+	SetState(state []float64)
+}
+
+// cloneScheduler copies a scheduler that is a pointer to a struct (like the built-ins) so clones don't share its state
+func cloneScheduler(scheduler Scheduler) Scheduler {
+	value := reflect.ValueOf(scheduler)
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return scheduler
+	}
+
+	clone := reflect.New(value.Elem().Type())
+	clone.Elem().Set(value.Elem())
+	return clone.Interface().(Scheduler)
+}
+
+// schedule sets the rate of every layer to a factor of its own rate: the one it had before the first schedule
+// (new layers of surgery too) or the one set on the layer after the last schedule
+func (neural *Neural) schedule(factor float64) {
+	for i := 0; i < neural.MaxLayers; i++ {
+		layer := neural.Layers[i]
+		if layer.baseRate == 0.0 || layer.Rate != layer.scheduledRate {
+			layer.baseRate = layer.Rate
+		}
+		layer.Rate = layer.baseRate * factor
+		layer.scheduledRate = layer.Rate
+	}
+}
+
+// StepDecay multiplies the rate by Gamma (default is 0.1) every N epochs (default is 1)
+type StepDecay struct {
+	Every int
+	Gamma float64
+	epoch int
+}
+
+// Step keeps the factor of the current epoch
+func (scheduler *StepDecay) Step(step int) float64 {
+	if scheduler.Every == 0 {
+		scheduler.Every = 1
+	}
+	if scheduler.Gamma == 0.0 {
+		scheduler.Gamma = 0.1
+	}
+	if scheduler.Every < 0 {
+		panic("need a positive every in step decay")
+	}
+
+	return math.Pow(scheduler.Gamma, float64(scheduler.epoch/scheduler.Every))
+}
+
+// Epoch decays the factor
+func (scheduler *StepDecay) Epoch(epoch int, loss float64) float64 {
+	scheduler.epoch = epoch
+	return scheduler.Step(0)
+}
+
+// State is the current epoch
+func (scheduler *StepDecay) State() []float64 {
+	return []float64{float64(scheduler.epoch)}
+}
+
+// SetState restores the current epoch
+func (scheduler *StepDecay) SetState(state []float64) {
+	scheduler.epoch = int(state[0])
+}
+
+// ExponentialDecay multiplies the rate by Gamma (default is 0.9) every epoch
+type ExponentialDecay struct {
+	Gamma float64
+	epoch int
+}
+
+// Step keeps the factor of the current epoch
+func (scheduler *ExponentialDecay) Step(step int) float64 {
+	if scheduler.Gamma == 0.0 {
+		scheduler.Gamma = 0.9
+	}
+	return math.Pow(scheduler.Gamma, float64(scheduler.epoch))
+}
+
+// Epoch decays the factor
+func (scheduler *ExponentialDecay) Epoch(epoch int, loss float64) float64 {
+	scheduler.epoch = epoch
+	return scheduler.Step(0)
+}
+
+// State is the current epoch
+func (scheduler *ExponentialDecay) State() []float64 {
+	return []float64{float64(scheduler.epoch)}
+}
+
+// SetState restores the current epoch
+func (scheduler *ExponentialDecay) SetState(state []float64) {
+	scheduler.epoch = int(state[0])
+}
+
+// CosineAnnealing goes from the full rate to Min following a cosine over Period epochs, then restarts
+// Every restart multiplies the period by Mult (default is 1)
+type CosineAnnealing struct {
+	Period int
+	Mult   int
+	Min    float64
+	epoch  int
+}
+
+// Step keeps the factor of the current epoch
+func (scheduler *CosineAnnealing) Step(step int) float64 {
+	if scheduler.Period < 1 {
+		panic("need a period of epochs in cosine annealing")
+	}
+	mult := scheduler.Mult
+	if mult == 0 {
+		mult = 1
+	}
+	if mult < 0 {
+		panic("need a positive mult in cosine annealing")
+	}
+
+	current, period := scheduler.epoch, scheduler.Period
+	for current >= period {
+		current -= period
+		period *= mult
+	}
+
+	return scheduler.Min + (1.0-scheduler.Min)*(1.0+math.Cos(math.Pi*float64(current)/float64(period)))/2.0
+}
+
+// Epoch anneals the factor
+func (scheduler *CosineAnnealing) Epoch(epoch int, loss float64) float64 {
+	scheduler.epoch = epoch
+	return scheduler.Step(0)
+}
+
+// State is the current epoch
+func (scheduler *CosineAnnealing) State() []float64 {
+	return []float64{float64(scheduler.epoch)}
+}
+
+// SetState restores the current epoch
+func (scheduler *CosineAnnealing) SetState(state []float64) {
+	scheduler.epoch = int(state[0])
+}
+
+// LinearWarmup goes from Start to the full rate in the first N steps
+type LinearWarmup struct {
+	Steps int
+	Start float64
+	step  int
+}
+
+// Step increases the factor until the warmup ends
+func (scheduler *LinearWarmup) Step(step int) float64 {
+	scheduler.step = step
+
+	if step >= scheduler.Steps {
+		return 1.0
+	}
+	return scheduler.Start + (1.0-scheduler.Start)*float64(step)/float64(scheduler.Steps)
+}
+
+// Epoch keeps the factor of the current step
+func (scheduler *LinearWarmup) Epoch(epoch int, loss float64) float64 {
+	return scheduler.Step(scheduler.step)
+}
+
+// State is the current step
+func (scheduler *LinearWarmup) State() []float64 {
+	return []float64{float64(scheduler.step)}
+}
+
+// SetState restores the current step
+func (scheduler *LinearWarmup) SetState(state []float64) {
+	scheduler.step = int(state[0])
+}
+
+// OneCycle increases the factor up to Max (default is 1) during the first part (Warmup, default is 0.3) of the total steps
+// and then anneals it down to almost zero, both following a cosine
+type OneCycle struct {
+	Steps  int
+	Max    float64
+	Warmup float64
+	step   int
+}
+
+// Step moves the factor along the cycle
+func (scheduler *OneCycle) Step(step int) float64 {
+	scheduler.step = step
+
+	if scheduler.Steps < 1 {
+		panic("need the total steps of one cycle")
+	}
+	if scheduler.Max == 0.0 {
+		scheduler.Max = 1.0
+	}
+	warmup := scheduler.Warmup
+	if warmup == 0.0 {
+		warmup = 0.3
+	}
+	if warmup < 0.0 || warmup >= 1.0 {
+		panic("need a warmup between 0 and 1 in one cycle")
+	}
+
+	start, end := scheduler.Max/25.0, scheduler.Max/1e4
+	peak := warmup * float64(scheduler.Steps)
+
+	if float64(step) < peak {
+		return cosineBetween(start, scheduler.Max, float64(step)/peak)
+	}
+
+	progress := math.Min((float64(step)-peak)/(float64(scheduler.Steps)-peak), 1.0)
+	return cosineBetween(scheduler.Max, end, progress)
+}
+
+// Epoch keeps the factor of the current step
+func (scheduler *OneCycle) Epoch(epoch int, loss float64) float64 {
+	return scheduler.Step(scheduler.step)
+}
+
+// State is the current step
+func (scheduler *OneCycle) State() []float64 {
+	return []float64{float64(scheduler.step)}
+}
+
+// SetState restores the current step
+func (scheduler *OneCycle) SetState(state []float64) {
+	scheduler.step = int(state[0])
+}
+
+// ReduceOnPlateau multiplies the rate by Factor (default is 0.1) when the loss didn't improve
+// by more than Threshold (relative) in Patience epochs, without going under Min
+type ReduceOnPlateau struct {
+	Factor    float64
+	Patience  int
+	Threshold float64
+	Min       float64
+	factor    float64
+	best      float64
+	bad       int
+}
+
+// Step keeps the current factor
+func (scheduler *ReduceOnPlateau) Step(step int) float64 {
+	if scheduler.factor == 0.0 {
+		scheduler.factor = 1.0
+		scheduler.best = math.Inf(1)
+	}
+	return scheduler.factor
+}
+
+// Epoch reduces the factor if the loss is on a plateau
+func (scheduler *ReduceOnPlateau) Epoch(epoch int, loss float64) float64 {
+	scheduler.Step(0)
+
+	if loss < scheduler.best*(1.0-scheduler.Threshold) {
+		scheduler.best = loss
+		scheduler.bad = 0
+		return scheduler.factor
+	}
+
+	scheduler.bad++
+	if scheduler.bad > scheduler.Patience {
+		factor := scheduler.Factor
+		if factor == 0.0 {
+			factor = 0.1
+		}
+		scheduler.factor = math.Max(scheduler.factor*factor, scheduler.Min)
+		scheduler.bad = 0
+	}
+
+	return scheduler.factor
+}
+
+// State is the current factor, the best loss (the max float instead of infinity) and the epochs without improving
+func (scheduler *ReduceOnPlateau) State() []float64 {
+	return []float64{scheduler.factor, math.Min(scheduler.best, math.MaxFloat64), float64(scheduler.bad)}
+}
+
+// SetState restores the current factor, best loss and epochs without improving
+func (scheduler *ReduceOnPlateau) SetState(state []float64) {
+	scheduler.factor, scheduler.best, scheduler.bad = state[0], state[1], int(state[2])
+}
+
+// cosineBetween interpolates from a to b with progress in [0, 1]
+func cosineBetween(a float64, b float64, progress float64) float64 {
+	return b + (a-b)*(1.0+math.Cos(math.Pi*progress))/2.0
+}
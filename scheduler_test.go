@@ -0,0 +1,91 @@
+package neural
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSchedulerDefaults(t *testing.T) {
+	decay := &StepDecay{}
+	if factor := decay.Epoch(3, 0); math.Abs(factor-1e-3) > 1e-15 {
+		t.Fatalf("step decay factor %v", factor)
+	}
+	if factor := (&ExponentialDecay{}).Epoch(2, 0); math.Abs(factor-0.81) > 1e-15 {
+		t.Fatalf("exponential decay factor %v", factor)
+	}
+
+	cycle := &OneCycle{Steps: 10}
+	for step := 0; step <= 12; step++ {
+		if factor := cycle.Step(step); !isFinite(factor) || factor <= 0.0 || factor > 1.0 {
+			t.Fatalf("one cycle factor %v at step %v", factor, step)
+		}
+	}
+	if factor := cycle.Step(3); factor != 1.0 {
+		t.Fatalf("one cycle peak %v", factor)
+	}
+
+	// the first period has 4 epochs and the second one 8 epochs
+	cosine := &CosineAnnealing{Period: 4, Mult: 2}
+	for epoch, expected := range map[int]float64{0: 1, 2: 0.5, 4: 1, 8: 0.5, 12: 1} {
+		if factor := cosine.Epoch(epoch, 0); math.Abs(factor-expected) > 1e-12 {
+			t.Fatalf("cosine factor %v at epoch %v", factor, epoch)
+		}
+	}
+}
+
+func TestSchedulerPanics(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func()
+	}{
+		{"cosine without period", func() { (&CosineAnnealing{}).Epoch(1, 0) }},
+		{"cosine with negative mult", func() { (&CosineAnnealing{Period: 2, Mult: -1}).Epoch(5, 0) }},
+		{"step decay with negative every", func() { (&StepDecay{Every: -1}).Epoch(1, 0) }},
+		{"one cycle without steps", func() { (&OneCycle{}).Step(0) }},
+		{"one cycle with the whole warmup", func() { (&OneCycle{Steps: 10, Warmup: 1}).Step(10) }},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("expected a panic")
+				}
+			}()
+			test.fn()
+		})
+	}
+}
+
+func TestSchedulerRates(t *testing.T) {
+	neural := NewNeural([]*Layer{{Inputs: 2, Units: 3, Rate: 0.1}, {Units: 1, Rate: 0.01}})
+	neural.Seed(1)
+	neural.Reset()
+	neural.Scheduler = &StepDecay{Gamma: 0.5}
+	dataset := [][][]float64{{{0, 1}, {1}}, {{1, 0}, {0}}}
+
+	expect := func(rates ...float64) {
+		t.Helper()
+		for i, rate := range rates {
+			if math.Abs(neural.Layers[i].Rate-rate) > 1e-15 {
+				t.Fatalf("layer %v has a rate of %v expected %v", i, neural.Layers[i].Rate, rate)
+			}
+		}
+	}
+
+	// every epoch halves the rates keeping the multiplier between layers
+	neural.LearnsRaw(dataset)
+	expect(0.05, 0.005)
+	neural.LearnsRaw(dataset)
+	expect(0.025, 0.0025)
+
+	// a new layer starts from its own rate and the others don't decay twice
+	neural.InsertLayer(1, &Layer{Units: 2, Rate: 0.2})
+	neural.LearnsRaw(dataset)
+	expect(0.0125, 0.025, 0.00125)
+
+	// a rate set by hand is the new own rate of the layer
+	neural.Layers[0].Rate = 0.4
+	neural.LearnsRaw(dataset)
+	expect(0.025, 0.0125, 0.000625)
+}
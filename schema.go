@@ -0,0 +1,288 @@
+package neural
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Schema declares the input columns of a neural and the labels of its outputs
+// It's exported with the neural so records with categorical values can be predicted
+type Schema struct {
+	Inputs []Column `json:"Inputs"`
+	// Categorical column of the labels
+	Target string `json:"Target,omitempty"`
+	// Labels of the outputs, one per output (highest wins) or two for a single output ([negative, positive])
+	Labels []string `json:"Labels,omitempty"`
+}
+
+// Column is an input column, type is numeric (default) or categorical
+// Categorical columns use onehot (default, an input per word) or ordinal encoding (index of the word)
+type Column struct {
+	Name       string   `json:"Name"`
+	Type       string   `json:"Type,omitempty"`
+	Encoding   string   `json:"Encoding,omitempty"`
+	Vocabulary []string `json:"Vocabulary,omitempty"`
+}
+
+// ReadCSV reads a dataset like ReadCSV with the categorical columns of the schema
+// Empty vocabularies (and labels) are filled with the words of the dataset, inputs and target are selected
+func (schema *Schema) ReadCSV(r io.Reader, separator rune) (*Dataset, error) {
+	dataset, err := readCSV(r, separator, schema)
+	if err != nil {
+		return nil, err
+	}
+	return dataset, schema.fit(dataset)
+}
+
+// ReadJSONL reads a dataset like ReadJSONL with the categorical columns of the schema
+// Empty vocabularies (and labels) are filled with the words of the dataset, inputs and target are selected
+func (schema *Schema) ReadJSONL(r io.Reader) (*Dataset, error) {
+	dataset, err := readJSONL(r, schema)
+	if err != nil {
+		return nil, err
+	}
+	return dataset, schema.fit(dataset)
+}
+
+// fit fills the empty vocabularies with the ones of a dataset and selects the columns of the schema
+func (schema *Schema) fit(dataset *Dataset) error {
+	for _, column := range schema.Inputs {
+		if column.Encoding != "" && column.Encoding != "onehot" && column.Encoding != "ordinal" {
+			panic("need a valid encoding")
+		}
+	}
+
+	inputs, targets := []string{}, []string{}
+	for _, column := range schema.Inputs {
+		inputs = append(inputs, column.Name)
+	}
+	if schema.Target != "" {
+		targets = append(targets, schema.Target)
+	}
+	if err := dataset.Select(inputs, targets); err != nil {
+		return err
+	}
+
+	for name, words := range dataset.Categories {
+		if vocabulary := schema.vocabulary(name); len(*vocabulary) == 0 {
+			*vocabulary = append([]string{}, words...)
+		}
+	}
+	return nil
+}
+
+// vocabulary of a categorical column (the labels of the target), nil if the column is numeric
+func (schema *Schema) vocabulary(name string) *[]string {
+	if schema == nil {
+		return nil
+	}
+
+	for i := range schema.Inputs {
+		if column := &schema.Inputs[i]; column.Name == name && column.Type == "categorical" {
+			return &column.Vocabulary
+		}
+	}
+	if schema.Target != "" && schema.Target == name {
+		return &schema.Labels
+	}
+	return nil
+}
+
+// Width is the amount of encoded inputs
+func (schema *Schema) Width() int {
+	width := 0
+	for _, column := range schema.Inputs {
+		width += len(column.names())
+	}
+	return width
+}
+
+// Outputs is the amount of outputs needed for the labels (one for two labels)
+func (schema *Schema) Outputs() int {
+	if len(schema.Labels) == 2 {
+		return 1
+	}
+	return len(schema.Labels)
+}
+
+// Encode converts a record (column name to text value) to input values
+// Unknown words are all zeros in onehot encoding
+func (schema *Schema) Encode(record map[string]string) ([]float64, error) {
+	inputs := make([]float64, 0, schema.Width())
+
+	for _, column := range schema.Inputs {
+		field := strings.TrimSpace(record[column.Name])
+
+		if column.Type != "categorical" {
+			value, err := parseValue(field)
+			if err != nil {
+				return nil, fmt.Errorf("column %v: %v", column.Name, err)
+			}
+			inputs = append(inputs, value)
+			continue
+		}
+
+		encoded, err := column.encode(field)
+		if err != nil {
+			return nil, err
+		}
+		inputs = append(inputs, encoded...)
+	}
+
+	return inputs, nil
+}
+
+// EncodeDataset creates a dataset with the encoded inputs (like country=US) and targets (like label=cat)
+func (schema *Schema) EncodeDataset(dataset *Dataset) (*Dataset, error) {
+	if len(schema.Labels) > 0 && schema.Target == "" {
+		return nil, errors.New("need a target column for the labels")
+	}
+
+	encoded := &Dataset{
+		Rows:       make([][]float64, len(dataset.Rows)),
+		Categories: map[string][]string{},
+	}
+
+	for _, column := range schema.Inputs {
+		encoded.Inputs = append(encoded.Inputs, column.names()...)
+	}
+
+	labels := Column{Name: "", Type: "categorical", Encoding: "onehot", Vocabulary: schema.Labels}
+	if len(schema.Labels) == 2 {
+		labels.Encoding = "ordinal"
+	}
+
+	if len(schema.Labels) > 0 {
+		labels.Name = schema.Target
+		encoded.Targets = labels.names()
+	} else {
+		encoded.Targets = dataset.Targets
+	}
+	encoded.Columns = append(append([]string{}, encoded.Inputs...), encoded.Targets...)
+
+	columns := append(append([]Column{}, schema.Inputs...), labels)
+
+	indexes := make([]int, len(columns))
+	for i, column := range columns {
+		if indexes[i] = dataset.column(column.Name); indexes[i] == -1 && column.Name != "" {
+			return nil, fmt.Errorf("column %v not found", column.Name)
+		}
+	}
+	targets := make([]int, len(dataset.Targets))
+	for t, target := range dataset.Targets {
+		if targets[t] = dataset.column(target); targets[t] == -1 {
+			return nil, fmt.Errorf("column %v not found", target)
+		}
+	}
+
+	for r, row := range dataset.Rows {
+		values := make([]float64, 0, len(encoded.Columns))
+
+		for i, column := range columns {
+			if column.Name == "" {
+				continue
+			}
+
+			value := row[indexes[i]]
+			if column.Type != "categorical" {
+				values = append(values, value)
+				continue
+			}
+
+			word := ""
+			if vocabulary := dataset.Categories[column.Name]; !math.IsNaN(value) && int(value) < len(vocabulary) {
+				word = vocabulary[int(value)]
+			}
+
+			words, err := column.encode(word)
+			if err != nil {
+				words = []float64{math.NaN()}
+			}
+			values = append(values, words...)
+		}
+
+		if len(schema.Labels) == 0 {
+			for _, target := range targets {
+				values = append(values, row[target])
+			}
+		}
+
+		encoded.Rows[r] = values
+	}
+
+	return encoded, nil
+}
+
+// Decode converts outputs to a label (the highest output, or the threshold 0.5 with a single output)
+func (schema *Schema) Decode(outputs []float64) string {
+	if len(schema.Labels) == 0 || len(outputs) == 0 {
+		return ""
+	}
+
+	if len(outputs) == 1 {
+		if outputs[0] >= 0.5 {
+			return schema.Labels[len(schema.Labels)-1]
+		}
+		return schema.Labels[0]
+	}
+
+	return schema.Labels[argmax(outputs)]
+}
+
+// Predict thinks a record (column name to text value) and returns the label (neural needs a schema)
+func (neural *Neural) Predict(record map[string]string) (string, error) {
+	if neural.Schema == nil {
+		return "", errors.New("need a schema to predict")
+	}
+
+	inputs, err := neural.Schema.Encode(record)
+	if err != nil {
+		return "", err
+	}
+
+	return neural.Schema.Decode(neural.Think(inputs)), nil
+}
+
+// names of the encoded inputs of the column
+func (column Column) names() []string {
+	if column.Type != "categorical" || column.Encoding == "ordinal" {
+		return []string{column.Name}
+	}
+
+	names := make([]string, len(column.Vocabulary))
+	for i, word := range column.Vocabulary {
+		names[i] = column.Name + "=" + word
+	}
+	return names
+}
+
+// encode a word of a categorical column, missing words are NaN in ordinal encoding
+func (column Column) encode(word string) ([]float64, error) {
+	index := -1
+	for i, known := range column.Vocabulary {
+		if known == word {
+			index = i
+			break
+		}
+	}
+
+	if column.Encoding == "ordinal" {
+		if missingValue(word) {
+			return []float64{math.NaN()}, nil
+		}
+		if index == -1 {
+			return nil, fmt.Errorf("column %v: unknown word %v", column.Name, strconv.Quote(word))
+		}
+		return []float64{float64(index)}, nil
+	}
+
+	encoded := make([]float64, len(column.Vocabulary))
+	if index != -1 {
+		encoded[index] = 1.0
+	}
+	return encoded, nil
+}
@@ -0,0 +1,172 @@
+package neural
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// animals is a schema with a numeric, a onehot and an ordinal column, and labels
+func animals() *Schema {
+	return &Schema{
+		Inputs: []Column{
+			{Name: "weight"},
+			{Name: "color", Type: "categorical"},
+			{Name: "size", Type: "categorical", Encoding: "ordinal", Vocabulary: []string{"small", "big"}},
+		},
+		Target: "label",
+	}
+}
+
+const animalsCSV = "weight,color,size,label\n4,black,small,cat\n30,brown,big,dog\n5,white,NA,cat\n3,black,small,mouse\n"
+
+func TestSchemaReadCSV(t *testing.T) {
+	schema := animals()
+	dataset, err := schema.ReadCSV(strings.NewReader(animalsCSV), ',')
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// empty vocabularies are filled, declared ones are kept
+	if !reflect.DeepEqual(schema.Inputs[1].Vocabulary, []string{"black", "brown", "white"}) || !reflect.DeepEqual(schema.Labels, []string{"cat", "dog", "mouse"}) {
+		t.Fatalf("vocabulary %v labels %v", schema.Inputs[1].Vocabulary, schema.Labels)
+	}
+	if !reflect.DeepEqual(dataset.Categories["size"], []string{"small", "big"}) {
+		t.Fatalf("vocabulary %v", dataset.Categories["size"])
+	}
+	if !reflect.DeepEqual(dataset.Inputs, []string{"weight", "color", "size"}) || !reflect.DeepEqual(dataset.Targets, []string{"label"}) {
+		t.Fatalf("inputs %v targets %v", dataset.Inputs, dataset.Targets)
+	}
+	if row := dataset.Rows[2]; row[0] != 5 || row[1] != 2 || !math.IsNaN(row[2]) || row[3] != 0 {
+		t.Fatalf("row %v", row)
+	}
+
+	// columns that are not categorical need numbers
+	if _, err := animals().ReadCSV(strings.NewReader("weight,color,size,label\n4,black,small,cat\nx,black,small,cat\n"), ','); err == nil || !strings.Contains(err.Error(), "line 3 column weight") {
+		t.Fatalf("expected an error of line 3 column weight, got %v", err)
+	}
+
+	// columns of the schema need to be in the dataset
+	if _, err := animals().ReadCSV(strings.NewReader("weight,color,label\n4,black,cat\n"), ','); err == nil {
+		t.Fatal("expected an error of a missing column")
+	}
+}
+
+func TestSchemaReadJSONL(t *testing.T) {
+	schema := animals()
+	schema.Labels = []string{"dog", "cat"}
+
+	dataset, err := schema.ReadJSONL(strings.NewReader(`{"weight": 4, "color": "black", "size": "small", "label": "cat"}
+{"weight": 9, "color": "red", "size": "big", "label": "bird"}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// unknown words only extend the vocabulary of the dataset
+	if !reflect.DeepEqual(schema.Labels, []string{"dog", "cat"}) || !reflect.DeepEqual(dataset.Categories["label"], []string{"dog", "cat", "bird"}) {
+		t.Fatalf("labels %v categories %v", schema.Labels, dataset.Categories["label"])
+	}
+	if dataset.Rows[0][3] != 1 || dataset.Rows[1][3] != 2 {
+		t.Fatalf("rows %v", dataset.Rows)
+	}
+}
+
+func TestEncodeDataset(t *testing.T) {
+	schema := animals()
+	dataset, err := schema.ReadCSV(strings.NewReader(animalsCSV), ',')
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encoded, err := schema.EncodeDataset(dataset)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	columns := []string{"weight", "color=black", "color=brown", "color=white", "size", "label=cat", "label=dog", "label=mouse"}
+	if !reflect.DeepEqual(encoded.Columns, columns) || schema.Width() != 5 || schema.Outputs() != 3 {
+		t.Fatalf("columns %v", encoded.Columns)
+	}
+	if !reflect.DeepEqual(encoded.Rows[1], []float64{30, 0, 1, 0, 1, 0, 1, 0}) {
+		t.Fatalf("row %v", encoded.Rows[1])
+	}
+	if row := encoded.Rows[2]; !math.IsNaN(row[4]) || row[3] != 1 {
+		t.Fatalf("row %v", row)
+	}
+
+	// two labels are a single output
+	binary := &Schema{Inputs: []Column{{Name: "weight"}}, Target: "label", Labels: []string{"cat", "dog"}}
+	encoded, err = binary.EncodeDataset(dataset)
+	if err != nil || !reflect.DeepEqual(encoded.Targets, []string{"label"}) || encoded.Rows[1][1] != 1 || encoded.Rows[0][1] != 0 {
+		t.Fatalf("targets %v rows %v (%v)", encoded.Targets, encoded.Rows, err)
+	}
+}
+
+func TestEncodeDatasetMissingColumn(t *testing.T) {
+	dataset := NewDataset([][][]float64{{{1, 2}, {0}}})
+
+	if _, err := animals().EncodeDataset(dataset); err == nil || !strings.Contains(err.Error(), "column weight not found") {
+		t.Fatalf("expected an error of a missing column, got %v", err)
+	}
+
+	labels := &Schema{Inputs: []Column{{Name: "x0"}}, Labels: []string{"no", "yes"}}
+	if _, err := labels.EncodeDataset(dataset); err == nil {
+		t.Fatal("expected an error of labels without target")
+	}
+}
+
+func TestPredict(t *testing.T) {
+	schema := animals()
+	dataset, err := schema.ReadCSV(strings.NewReader(animalsCSV), ',')
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := schema.EncodeDataset(dataset)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	neural := NewNeural([]*Layer{{Inputs: schema.Width(), Units: 4}, {Units: schema.Outputs()}})
+	if _, err := neural.Predict(map[string]string{"weight": "4"}); err == nil {
+		t.Fatal("expected an error without schema")
+	}
+
+	neural.Schema = schema
+	neural.Seed(1)
+	neural.Reset()
+	encoded = encoded.DropMissing()
+	for epoch := 0; epoch < 2000; epoch++ {
+		neural.LearnsDataset(encoded)
+	}
+	if err := neural.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	record := map[string]string{"weight": "30", "color": "brown", "size": "big"}
+	if label, err := neural.Predict(record); err != nil || label != "dog" {
+		t.Fatalf("predicted %v (%v)", label, err)
+	}
+
+	// the schema is exported with the neural
+	exported, err := neural.Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := NewNeural([]*Layer{})
+	if err := imported.Import(exported); err != nil {
+		t.Fatal(err)
+	}
+	if label, err := imported.Predict(record); err != nil || label != "dog" {
+		t.Fatalf("imported neural predicted %v (%v)", label, err)
+	}
+
+	// unknown words are zeros in onehot encoding but an error in ordinal encoding
+	if _, err := imported.Predict(map[string]string{"weight": "30", "color": "green", "size": "big"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := imported.Predict(map[string]string{"weight": "30", "color": "brown", "size": "huge"}); err == nil {
+		t.Fatal("expected an error of an unknown ordinal word")
+	}
+}
@@ -0,0 +1,242 @@
+package neural
+
+import (
+	"math"
+)
+
+// A sequence is a list of steps, every step goes through all layers and recurrent layers keep their state.
+// Many-to-many learns one output per step, many-to-one learns a single output for the last step.
+// Long sequences can be backpropagated in chunks of Truncate steps (truncated backpropagation through time),
+// the weights are updated after every chunk and the state continues to the next one.
+
+// layerState is what a forward leaves in a layer for its backward, kept for every step of a sequence
+type layerState struct {
+	mask []float64
+	kind interface{}
+}
+
+// neuronState is the inputs and activations of the neurons (the state of layers like dense)
+type neuronState struct {
+	inputs      [][]float64
+	activations []float64
+}
+
+// ThinkSequence process every step of a sequence of arbitrary values and returns the outputs of every step
+// The state of recurrent layers starts empty unless they are stateful
+func (neural *Neural) ThinkSequence(inputs [][]float64) [][]float64 {
+	neural.resetStates(false)
+
+	outputs := make([][]float64, len(inputs))
+	for t, step := range inputs {
+		outputs[t] = neural.Think(step)
+	}
+	return outputs
+}
+
+// ThinkSequenceRaw process every step of a raw sequence and returns the raw outputs of every step
+func (neural *Neural) ThinkSequenceRaw(inputs [][]float64) [][]float64 {
+	neural.resetStates(false)
+
+	outputs := make([][]float64, len(inputs))
+	for t, step := range inputs {
+		outputs[t] = neural.ThinkRaw(step)
+	}
+	return outputs
+}
+
+// LearnSequence learns a sequence of arbitrary values by automatic conversion to raw values
+func (neural *Neural) LearnSequence(inputs [][]float64, outputs [][]float64) float64 {
+	rawInputs := make([][]float64, len(inputs))
+	for t, step := range inputs {
+		rawInputs[t] = neural.InputValuesToRaw(step)
+	}
+
+	rawOutputs := make([][]float64, len(outputs))
+	for t, step := range outputs {
+		rawOutputs[t] = neural.OutputValuesToRaw(step)
+	}
+
+	return neural.LearnSequenceRaw(rawInputs, rawOutputs)
+}
+
+// LearnSequenceRaw uses backpropagation through time, outputs are one per step (many-to-many)
+// or only one for the last step (many-to-one). It returns the loss (mse) of the outputs
+func (neural *Neural) LearnSequenceRaw(inputs [][]float64, outputs [][]float64) float64 {
+	if neural.err != nil {
+		return math.NaN()
+	}
+	if len(outputs) != len(inputs) && len(outputs) != 1 {
+		panic("need an output per step or only one for the last step")
+	}
+
+	// targets of every step, nil when the step doesn't have an output
+	targets := make([][]float64, len(inputs))
+	if len(outputs) == len(inputs) {
+		copy(targets, outputs)
+	} else {
+		targets[len(inputs)-1] = outputs[0]
+	}
+
+	truncate := neural.Truncate
+	if truncate <= 0 {
+		truncate = len(inputs)
+	}
+
+	neural.resetStates(false)
+	defer neural.clearCarries()
+
+	loss := 0.0
+	for from := 0; from < len(inputs); from += truncate {
+		to := from + truncate
+		if to > len(inputs) {
+			to = len(inputs)
+		}
+
+		chunk, err := neural.learnChunk(inputs[from:to], targets[from:to])
+		if err != nil {
+			return math.NaN()
+		}
+		loss += chunk
+	}
+
+	return loss / float64(len(outputs))
+}
+
+// learnChunk learns the steps of a chunk, the weights are updated once
+func (neural *Neural) learnChunk(inputs [][]float64, targets [][]float64) (float64, error) {
+	loss, learned := neural.backwardChunk(inputs, targets, true)
+
+	// the state continues but a chunk without outputs has nothing to learn
+	if learned == 0 {
+		return 0.0, nil
+	}
+	return loss, neural.Step()
+}
+
+// backwardChunk runs the steps of a chunk and backpropagates them from the last one to the first one
+// The gradients are the average of the steps with outputs, it returns the loss and the amount of them
+func (neural *Neural) backwardChunk(inputs [][]float64, targets [][]float64, training bool) (float64, int) {
+	states := make([][]layerState, len(inputs))
+	currents := make([][]float64, len(inputs))
+	learned := 0
+
+	for t, step := range inputs {
+		currents[t] = neural.forward(step, training)
+		states[t] = make([]layerState, neural.MaxLayers)
+		for i := 0; i < neural.MaxLayers; i++ {
+			states[t][i] = neural.Layers[i].state()
+		}
+		if targets[t] != nil {
+			learned++
+		}
+	}
+
+	if learned == 0 {
+		return 0.0, 0
+	}
+
+	neural.ZeroGrad()
+	neural.clearCarries()
+	for i := 0; i < neural.MaxLayers; i++ {
+		if layer := neural.Layers[i]; layer.recurrent() != nil {
+			layer.recurrent().carryHidden, layer.recurrent().carryCell = make([]float64, layer.Units), make([]float64, layer.Units)
+		}
+	}
+
+	loss := 0.0
+	last := neural.Layers[neural.MaxLayers-1]
+	for t := len(inputs) - 1; t >= 0; t-- {
+		errors := make([]float64, last.Units)
+		if targets[t] != nil {
+			var current float64
+			current, errors = lossErrors("mse", currents[t], targets[t])
+			loss += current
+		}
+
+		for i := neural.MaxLayers - 1; i >= 0; i-- {
+			neural.Layers[i].setState(states[t][i])
+			errors = neural.Layers[i].backward(errors)
+		}
+	}
+
+	grads := neural.Grads()
+	for p := range grads {
+		grads[p] /= float64(learned)
+	}
+	neural.setGrads(grads)
+
+	return loss, learned
+}
+
+// ResetState clears the state of all recurrent layers (also the stateful ones)
+func (neural *Neural) ResetState() {
+	neural.resetStates(true)
+}
+
+// resetStates clears the state of recurrent layers that are not stateful (or all of them)
+func (neural *Neural) resetStates(all bool) {
+	for i := 0; i < neural.MaxLayers; i++ {
+		if layer := neural.Layers[i]; layer.isRecurrent() && (all || !layer.Stateful) {
+			layer.ResetState()
+		}
+	}
+}
+
+// clearCarries stops passing errors of the state between backpropagations
+func (neural *Neural) clearCarries() {
+	for i := 0; i < neural.MaxLayers; i++ {
+		if recurrent := neural.Layers[i].recurrent(); recurrent != nil {
+			recurrent.carryHidden, recurrent.carryCell = nil, nil
+		}
+	}
+}
+
+// states returns a copy of the hidden state and cell of every layer (nil if it's not recurrent)
+func (neural *Neural) states() [][][]float64 {
+	states := make([][][]float64, neural.MaxLayers)
+	for i := 0; i < neural.MaxLayers; i++ {
+		if recurrent := neural.Layers[i].recurrent(); recurrent != nil {
+			states[i] = [][]float64{append([]float64(nil), recurrent.hidden...), append([]float64(nil), recurrent.cell...)}
+		}
+	}
+	return states
+}
+
+// setStates restores the hidden state and cell of every layer
+func (neural *Neural) setStates(states [][][]float64) {
+	for i := 0; i < neural.MaxLayers; i++ {
+		if recurrent := neural.Layers[i].recurrent(); recurrent != nil {
+			recurrent.hidden = append([]float64(nil), states[i][0]...)
+			recurrent.cell = append([]float64(nil), states[i][1]...)
+		}
+	}
+}
+
+// state of the layer after a forward
+func (layer *Layer) state() layerState {
+	return layerState{mask: layer.mask, kind: layer.kind().State(layer)}
+}
+
+// setState restores the state of the layer to backpropagate that forward
+func (layer *Layer) setState(state layerState) {
+	layer.mask = state.mask
+	layer.kind().SetState(layer, state.kind)
+}
+
+// neuronState of the last forward
+func (layer *Layer) neuronState() neuronState {
+	state := neuronState{make([][]float64, len(layer.Neurons)), make([]float64, len(layer.Neurons))}
+	for n, neuron := range layer.Neurons {
+		state.inputs[n] = append([]float64(nil), neuron.Inputs...)
+		state.activations[n] = neuron.activation
+	}
+	return state
+}
+
+// setNeuronState restores the inputs and activations of the neurons
+func (layer *Layer) setNeuronState(state neuronState) {
+	for n, neuron := range layer.Neurons {
+		copy(neuron.Inputs, state.inputs[n])
+		neuron.activation = state.activations[n]
+	}
+}
@@ -0,0 +1,246 @@
+package neural
+
+// InsertLayer adds a layer (simple layer definition) at index i, the following layer is reconnected
+// Inputs of the new layer are the units of the previous one (or the neural inputs at index 0)
+func (neural *Neural) InsertLayer(i int, layer *Layer) {
+	if i < 0 || i > neural.MaxLayers {
+		panic("need a valid layer index")
+	}
+
+	if i > 0 {
+		layer.inheritShape(neural.Layers[i-1])
+		layer.Inputs = neural.Layers[i-1].Units
+	} else if layer.Inputs == 0 && layer.Channels > 0 {
+		layer.Inputs = layer.shapeInputs()
+	} else if layer.Inputs == 0 {
+		layer.Inputs = neural.Layers[0].Inputs
+	}
+	if layer.Units == 0 && layer.isNorm() {
+		layer.Units = layer.Inputs
+	}
+	if layer.Random == nil {
+		layer.Random = neural.Random
+	}
+
+	NewLayer(layer)
+
+	// the new layer takes the ranges of inputs or outputs unless it has its own
+	inputs, outputs := neural.inputRanges(), neural.outputRanges()
+	if own := (valueRanges{layer.Range, layer.Scalers}); !own.empty() && i == 0 {
+		inputs = own
+	} else if !own.empty() && i == neural.MaxLayers {
+		outputs = own
+	}
+
+	neural.Layers = append(neural.Layers[:i], append([]*Layer{layer}, neural.Layers[i:]...)...)
+	neural.MaxLayers = len(neural.Layers)
+	neural.setRanges(inputs, outputs)
+
+	neural.connect(i + 1)
+}
+
+// RemoveLayer deletes the layer at index i, the following layer is reconnected
+func (neural *Neural) RemoveLayer(i int) {
+	if i < 0 || i >= neural.MaxLayers || neural.MaxLayers == 1 {
+		panic("need a valid layer index")
+	}
+
+	removed := neural.Layers[i]
+	inputs, outputs := neural.inputRanges(), neural.outputRanges()
+	neural.Layers = append(neural.Layers[:i], neural.Layers[i+1:]...)
+	neural.MaxLayers = len(neural.Layers)
+
+	if i == 0 {
+		neural.Layers[0].resize(removed.Inputs)
+		neural.connect(1)
+	} else if i < neural.MaxLayers {
+		neural.connect(i)
+	} else if neural.Layers[i-1].Units != removed.Units {
+		// the range of outputs is kept if units are the same
+		outputs = valueRanges{}
+	}
+
+	neural.setRanges(inputs, outputs)
+}
+
+// ReplaceHead changes the output layer for a new one with different units and activation
+// The range and scalers of outputs are kept if units are the same
+func (neural *Neural) ReplaceHead(units int, activation string) {
+	old := neural.Layers[neural.MaxLayers-1]
+	inputs, outputs := neural.inputRanges(), neural.outputRanges()
+
+	head := old.definition()
+	head.Type = ""
+	head.Units = units
+	head.Activation = activation
+	head.Dropout = 0.0
+	head.Frozen = false
+	NewLayer(head)
+
+	if units != old.Units {
+		outputs = valueRanges{}
+	}
+
+	neural.Layers[neural.MaxLayers-1] = head
+	neural.setRanges(inputs, outputs)
+}
+
+// Append stacks a copy of the layers of another neural after the output layer
+// The first appended layer is reconnected if its inputs don't match
+func (neural *Neural) Append(neuralB *Neural) {
+	from := neural.MaxLayers
+	inputs, outputs := neural.inputRanges(), neuralB.outputRanges()
+
+	for i := 0; i < neuralB.MaxLayers; i++ {
+		neural.Layers = append(neural.Layers, neuralB.Layers[i].Clone())
+	}
+	neural.MaxLayers = len(neural.Layers)
+
+	// the old output layer is hidden now, the outputs are the ones of the appended neural
+	neural.setRanges(inputs, outputs)
+	neural.connect(from)
+}
+
+// Slice creates a sub-neural with a copy of the layers in the range [from, to) (like an encoder)
+func (neural *Neural) Slice(from int, to int) *Neural {
+	if from < 0 || to > neural.MaxLayers || from >= to {
+		panic("need a valid layer range")
+	}
+
+	slice := NewNeural([]*Layer{})
+	slice.MaxLayers = to - from
+	slice.Layers = make([]*Layer, slice.MaxLayers)
+	slice.Random = neural.Random
+	slice.ClipValue = neural.ClipValue
+	slice.ClipNorm = neural.ClipNorm
+	slice.Batch = neural.Batch
+	slice.Workers = neural.Workers
+	slice.Truncate = neural.Truncate
+
+	for i := from; i < to; i++ {
+		slice.Layers[i-from] = neural.Layers[i].Clone()
+	}
+
+	// ranges are kept for the inputs and outputs of the neural only
+	inputs, outputs := valueRanges{}, valueRanges{}
+	if from == 0 {
+		inputs = neural.inputRanges()
+	}
+	if to == neural.MaxLayers {
+		outputs = neural.outputRanges()
+	}
+	slice.setRanges(inputs, outputs)
+
+	return slice
+}
+
+// Widen grows a layer (like dense) up to an amount of units keeping the same outputs (Net2Net)
+// New units are copies of random units and the next layer divides their outgoing weights by the copies,
+// elementwise layers in between (like batchnorm) also copy the units (gamma, beta and statistics)
+func (neural *Neural) Widen(i int, units int) {
+	if i < 0 || i >= neural.MaxLayers-1 {
+		panic("need a valid hidden layer index")
+	}
+
+	n := i + 1
+	for n < neural.MaxLayers-1 && neural.Layers[n].elementwise() {
+		n++
+	}
+
+	layer, next := neural.Layers[i], neural.Layers[n]
+	if layer.widener() == nil || layer.elementwise() || next.widener() == nil || next.elementwise() {
+		panic("need layers that can widen")
+	}
+	if units <= layer.Units {
+		return
+	}
+
+	copies := make([]int, units)
+	sources := make([]int, units)
+	for u := 0; u < units; u++ {
+		sources[u] = u
+		if u >= layer.Units {
+			sources[u] = layer.Random.Intn(layer.Units)
+		}
+		copies[sources[u]]++
+	}
+
+	layer.widener().WidenUnits(layer, sources)
+	for b := i + 1; b <= n; b++ {
+		neural.Layers[b].widener().WidenInputs(neural.Layers[b], sources, copies)
+	}
+}
+
+// Deepen inserts an identity layer after layer i keeping the same outputs (Net2Net)
+// The new layer is relu if the previous one is relu, otherwise it's linear
+func (neural *Neural) Deepen(i int) {
+	if i < 0 || i >= neural.MaxLayers {
+		panic("need a valid layer index")
+	}
+
+	previous := neural.Layers[i]
+	layer := previous.definition()
+	layer.Type = ""
+	layer.Inputs = 0
+	layer.Units = previous.Units
+	layer.Activation = "linear"
+	layer.Dropout = 0.0
+	layer.Frozen = false
+	if previous.Activation == "relu" {
+		layer.Activation = "relu"
+	}
+
+	neural.InsertLayer(i+1, layer)
+
+	for n, neuron := range layer.Neurons {
+		for w := range neuron.Weights {
+			neuron.Weights[w] = 0.0
+		}
+		neuron.Weights[n] = 1.0
+		neuron.Bias = 0.0
+	}
+}
+
+// connect resizes the inputs of the layers from index i to match the units of their previous layer
+// Layers that change their units too (like normalization) continue the resize with the next layer
+func (neural *Neural) connect(i int) {
+	for ; i > 0 && i < neural.MaxLayers; i++ {
+		layer := neural.Layers[i]
+		inputs, units := neural.Layers[i-1].Units, layer.Units
+
+		if layer.Inputs == inputs {
+			return
+		}
+
+		layer.resize(inputs)
+
+		if layer.Units == units {
+			return
+		}
+	}
+}
+
+// resize changes the amount of inputs keeping the weights that still fit (new ones are random)
+func (layer *Layer) resize(inputs int) {
+	kind, ok := layer.kind().(ResizeKind)
+	if !ok {
+		panic("need the same inputs to reconnect " + layer.Type + " layers")
+	}
+	kind.Resize(layer, inputs)
+}
+
+// widenNeurons appends a copy of the neuron of every source after the current ones
+func (layer *Layer) widenNeurons(sources []int) {
+	for _, source := range sources[len(layer.Neurons):] {
+		neuron := layer.Neurons[source].Clone()
+		neuron.Layer = layer
+		layer.Neurons = append(layer.Neurons, neuron)
+	}
+	layer.Units = len(layer.Neurons)
+}
+
+// elementwise is true if the layer can widen with the previous one
+func (layer *Layer) elementwise() bool {
+	widen := layer.widener()
+	return widen != nil && widen.Elementwise(layer)
+}
@@ -0,0 +1,313 @@
+package neural
+
+import (
+	"math"
+	"testing"
+)
+
+// ranged creates a neural with ranges of inputs (0-10) and outputs (0-100), units are per layer
+func ranged(inputs int, units ...int) *Neural {
+	layers := make([]*Layer, len(units))
+	for i, total := range units {
+		layers[i] = &Layer{Units: total}
+	}
+	layers[0].Inputs = inputs
+
+	layers[0].Range = make([][]float64, inputs)
+	for i := range layers[0].Range {
+		layers[0].Range[i] = []float64{0, 10}
+	}
+	// a single layer keeps the ranges of inputs followed by the ones of outputs
+	last := layers[len(layers)-1]
+	for u := 0; u < last.Units; u++ {
+		last.Range = append(last.Range, []float64{0, 100})
+	}
+
+	neural := NewNeural(layers)
+	neural.Seed(1)
+	neural.Reset()
+	return neural
+}
+
+func TestRemoveLastLayerRange(t *testing.T) {
+	tests := []struct {
+		name    string
+		units   []int
+		outputs int
+		ranged  bool
+	}{
+		{"single layer left", []int{3, 1}, 3, false},
+		{"different units", []int{3, 3, 1}, 3, false},
+		{"same units", []int{3, 1, 1}, 1, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			neural := ranged(2, test.units...)
+			neural.RemoveLayer(neural.MaxLayers - 1)
+
+			outputs := neural.Think([]float64{5, 5})
+			if len(outputs) != test.outputs {
+				t.Fatalf("%v outputs, expected %v", len(outputs), test.outputs)
+			}
+			if len(neural.Layers[0].Range) != 2 && neural.MaxLayers > 1 {
+				t.Fatal("the range of inputs changed")
+			}
+			raw := neural.ThinkRaw(neural.InputValuesToRaw([]float64{5, 5}))
+			if test.ranged && math.Abs(outputs[0]-100*raw[0]) > 1e-9 {
+				t.Fatalf("output %v is not in the range of outputs (raw %v)", outputs[0], raw[0])
+			}
+		})
+	}
+}
+
+func TestAppendKeepsInputRange(t *testing.T) {
+	neural := NewNeural([]*Layer{{Inputs: 2, Units: 2, Range: [][]float64{{0, 10}, {0, 10}}}})
+	raw := neural.InputValuesToRaw([]float64{5, 10})
+
+	neural.Append(ranged(2, 1))
+
+	if len(neural.Layers[0].Range) != 2 {
+		t.Fatal("the range of inputs was removed")
+	}
+	if appended := neural.InputValuesToRaw([]float64{5, 10}); appended[0] != raw[0] || appended[1] != raw[1] {
+		t.Fatalf("inputs are converted to %v instead of %v", appended, raw)
+	}
+	if outputs := neural.Think([]float64{5, 10}); len(outputs) != 1 || outputs[0] < 0 || outputs[0] > 100 {
+		t.Fatalf("outputs %v", outputs)
+	}
+}
+
+// preserves checks that a change of the architecture keeps the same outputs
+func preserves(t *testing.T, neural *Neural, change func()) {
+	t.Helper()
+
+	samples := [][]float64{{0.3, -0.8, 0.5}, {0.1, 0.4, -0.9}, {-0.6, 0.2, 0.7}}
+	before := make([][]float64, len(samples))
+	for s, sample := range samples {
+		before[s] = neural.ThinkRaw(sample)
+	}
+
+	change()
+
+	for s, sample := range samples {
+		after := neural.ThinkRaw(sample)
+		for o := range after {
+			if math.Abs(after[o]-before[s][o]) > 1e-12 {
+				t.Fatalf("output %v of sample %v changed from %v to %v", o, s, before[s][o], after[o])
+			}
+		}
+	}
+}
+
+// trained sets random params and statistics so identities are not trivial
+func trained(layers []*Layer) *Neural {
+	neural := NewNeural(layers)
+	neural.Seed(3)
+	neural.Reset()
+
+	random := NewRandom(7)
+	params := neural.Params()
+	for p := range params {
+		params[p] = random.Float64()*2.0 - 1.0
+	}
+	neural.SetParams(params)
+
+	for i := 0; i < neural.MaxLayers; i++ {
+		layer := neural.Layers[i]
+		for u := range layer.Mean {
+			layer.Mean[u], layer.Variance[u] = random.Float64()-0.5, 0.5+random.Float64()
+		}
+	}
+	return neural
+}
+
+func TestWidenKeepsOutputs(t *testing.T) {
+	tests := []struct {
+		name   string
+		layers []*Layer
+		widen  int
+	}{
+		{"relu", []*Layer{{Inputs: 3, Units: 4, Activation: "relu"}, {Units: 2, Activation: "relu"}, {Units: 1, Activation: "linear"}}, 0},
+		{"linear", []*Layer{{Inputs: 3, Units: 4, Activation: "linear"}, {Units: 2, Activation: "linear"}, {Units: 2, Activation: "tanh"}}, 1},
+		{"sigmoid", []*Layer{{Inputs: 3, Units: 2}, {Units: 3}}, 0},
+		{"batchnorm before", []*Layer{{Inputs: 3, Type: "batchnorm"}, {Units: 4, Activation: "relu"}, {Units: 1}}, 1},
+		{"batchnorm after", []*Layer{{Inputs: 3, Units: 4, Activation: "relu"}, {Type: "batchnorm", Activation: "tanh"}, {Units: 2}}, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			neural := trained(test.layers)
+			units := neural.Layers[test.widen].Units + 3
+			preserves(t, neural, func() { neural.Widen(test.widen, units) })
+
+			if neural.Layers[test.widen].Units != units || neural.NumParams() == 0 {
+				t.Fatalf("layer has %v units, expected %v", neural.Layers[test.widen].Units, units)
+			}
+		})
+	}
+}
+
+func TestDeepenKeepsOutputs(t *testing.T) {
+	tests := []struct {
+		name   string
+		layers []*Layer
+		deepen int
+	}{
+		{"relu", []*Layer{{Inputs: 3, Units: 4, Activation: "relu"}, {Units: 1}}, 0},
+		{"linear", []*Layer{{Inputs: 3, Units: 4, Activation: "linear"}, {Units: 1}}, 0},
+		{"output", []*Layer{{Inputs: 3, Units: 4}, {Units: 2, Activation: "relu"}}, 1},
+		{"batchnorm before", []*Layer{{Inputs: 3, Units: 4, Activation: "tanh"}, {Type: "batchnorm"}, {Units: 1}}, 1},
+		{"batchnorm after", []*Layer{{Inputs: 3, Units: 4, Activation: "relu"}, {Type: "batchnorm"}, {Units: 1}}, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			neural := trained(test.layers)
+			layers := neural.MaxLayers
+			preserves(t, neural, func() { neural.Deepen(test.deepen) })
+
+			if neural.MaxLayers != layers+1 {
+				t.Fatal("no layer was inserted")
+			}
+		})
+	}
+}
+
+func TestSingleLayerRanges(t *testing.T) {
+	// ranges of inputs followed by the ones of outputs
+	neural := NewNeural([]*Layer{{Inputs: 2, Units: 1, Range: [][]float64{{0, 10}, {0, 10}, {0, 100}}}})
+	if raw := neural.InputValuesToRaw([]float64{5, 10}); len(raw) != 2 || raw[0] != 0.5 || raw[1] != 1 {
+		t.Fatalf("inputs are converted to %v", raw)
+	}
+	if outputs := neural.OutputValuesFromRaw([]float64{0.5}); len(outputs) != 1 || outputs[0] != 50 {
+		t.Fatalf("outputs are converted to %v", outputs)
+	}
+
+	// the same ranges for inputs and outputs
+	neural = NewNeural([]*Layer{{Inputs: 2, Units: 2, Range: [][]float64{{0, 10}, {0, 100}}}})
+	if raw := neural.InputValuesToRaw([]float64{5, 50}); raw[0] != 0.5 || raw[1] != 0.5 {
+		t.Fatalf("inputs are converted to %v", raw)
+	}
+	if outputs := neural.OutputValuesFromRaw([]float64{0.5, 0.5}); outputs[0] != 5 || outputs[1] != 50 {
+		t.Fatalf("outputs are converted to %v", outputs)
+	}
+}
+
+// inRange checks that every output is in the range of outputs (0-100) but not raw
+func inRange(t *testing.T, neural *Neural) {
+	t.Helper()
+
+	for _, output := range neural.Think([]float64{5, 5}) {
+		if output <= 1 || output >= 100 {
+			t.Fatalf("output %v is not in the range of outputs", output)
+		}
+	}
+}
+
+func TestInsertLayerRanges(t *testing.T) {
+	t.Run("first", func(t *testing.T) {
+		neural := ranged(2, 3, 1)
+		raw := neural.InputValuesToRaw([]float64{5, 10})
+
+		neural.InsertLayer(0, &Layer{Units: 4})
+		if len(neural.Layers[0].Range) != 2 || len(neural.Layers[1].Range) != 0 {
+			t.Fatalf("ranges of %v and %v values", len(neural.Layers[0].Range), len(neural.Layers[1].Range))
+		}
+		if moved := neural.InputValuesToRaw([]float64{5, 10}); moved[0] != raw[0] || moved[1] != raw[1] {
+			t.Fatalf("inputs are converted to %v instead of %v", moved, raw)
+		}
+		inRange(t, neural)
+	})
+
+	t.Run("first of a single layer", func(t *testing.T) {
+		neural := ranged(2, 1)
+		neural.InsertLayer(0, &Layer{Units: 4})
+
+		if len(neural.Layers[0].Range) != 2 || len(neural.Layers[1].Range) != 1 {
+			t.Fatalf("ranges of %v and %v values", len(neural.Layers[0].Range), len(neural.Layers[1].Range))
+		}
+		inRange(t, neural)
+	})
+
+	t.Run("head", func(t *testing.T) {
+		neural := ranged(2, 3, 1)
+		neural.InsertLayer(2, &Layer{Units: 1, Activation: "tanh"})
+
+		if len(neural.Layers[1].Range) != 0 || len(neural.Layers[2].Range) != 1 {
+			t.Fatal("the range of outputs wasn't moved to the new head")
+		}
+		if r := neural.Layers[2].Range[0]; r[2] != -1 || r[3] != 1 {
+			t.Fatalf("range %v is not adapted to the activation", r)
+		}
+		inRange(t, neural)
+	})
+
+	t.Run("own range", func(t *testing.T) {
+		neural := ranged(2, 3, 1)
+		neural.InsertLayer(0, &Layer{Inputs: 2, Units: 4, Range: [][]float64{{0, 1}, {0, 1}}})
+
+		if raw := neural.InputValuesToRaw([]float64{0.5, 1}); raw[0] != 0.5 || raw[1] != 1 {
+			t.Fatalf("inputs are converted to %v", raw)
+		}
+	})
+}
+
+func TestDeepenKeepsRangedOutputs(t *testing.T) {
+	for _, units := range [][]int{{3, 1}, {1}} {
+		neural := ranged(2, units...)
+		before := neural.Think([]float64{5, 5})
+
+		neural.Deepen(neural.MaxLayers - 1)
+
+		after := neural.Think([]float64{5, 5})
+		if len(neural.outputRanges().Range) != 1 || math.Abs(after[0]-before[0]) > 1e-9 {
+			t.Fatalf("layers %v: output changed from %v to %v", units, before, after)
+		}
+	}
+}
+
+func TestReplaceHeadRanges(t *testing.T) {
+	neural := ranged(2, 3, 1)
+	neural.ReplaceHead(1, "tanh")
+	if len(neural.Layers[0].Range) != 2 || len(neural.Layers[1].Range) != 1 {
+		t.Fatal("ranges of the same units were dropped")
+	}
+	inRange(t, neural)
+
+	neural.ReplaceHead(2, "sigmoid")
+	if len(neural.Layers[0].Range) != 2 || len(neural.Layers[1].Range) != 0 {
+		t.Fatal("kept the range of outputs with different units")
+	}
+	if outputs := neural.Think([]float64{5, 5}); len(outputs) != 2 || outputs[0] > 1 {
+		t.Fatalf("outputs %v are not raw", outputs)
+	}
+}
+
+func TestSlice(t *testing.T) {
+	neural := ranged(2, 3, 4, 1)
+
+	encoder := neural.Slice(0, 2)
+	inputs := []float64{0.2, 0.7}
+	hidden := neural.Layers[1].Think(neural.Layers[0].Think(inputs))
+	if outputs := encoder.ThinkRaw(inputs); len(outputs) != 4 || outputs[0] != hidden[0] || outputs[3] != hidden[3] {
+		t.Fatalf("outputs %v instead of %v", outputs, hidden)
+	}
+	if len(encoder.Layers[0].Range) != 2 || len(encoder.Layers[1].Range) != 0 {
+		t.Fatal("the encoder should keep the range of inputs only")
+	}
+
+	decoder := neural.Slice(2, 3)
+	if outputs := decoder.ThinkRaw(hidden); outputs[0] != neural.ThinkRaw(inputs)[0] {
+		t.Fatalf("output %v instead of %v", outputs[0], neural.ThinkRaw(inputs)[0])
+	}
+	if raw := decoder.InputValuesToRaw(hidden); len(decoder.outputRanges().Range) != 1 || math.Abs(raw[0]-hidden[0]) > 1e-12 || math.Abs(raw[3]-hidden[3]) > 1e-12 {
+		t.Fatal("the decoder should keep the range of outputs only")
+	}
+
+	// layers are copies
+	encoder.Layers[0].Neurons[0].Weights[0] += 1.0
+	if neural.Layers[0].Neurons[0].Weights[0] == encoder.Layers[0].Neurons[0].Weights[0] {
+		t.Fatal("the slice shares the layers")
+	}
+}
@@ -0,0 +1,381 @@
+package neural
+
+import (
+	"encoding/json"
+	"math"
+)
+
+// Transformer layers are encoder blocks over a Sequence of tokens of Dimensions values (token after token):
+// multi-head self-attention (softmax(Q·Kᵀ/√d)·V of every head, concatenated and projected), then a feed-forward
+// of Hidden units with the activation of the layer (default is relu), each one followed by a residual and a layernorm.
+// Positional layers add the sinusoidal encoding of the position to every token (no params), use them before the first block.
+// Neurons are grouped: query, key, value and output projections, first norm, hidden, feed-forward output and second norm
+
+// attentionStep is what the last forward of a transformer layer keeps for the backward
+type attentionStep struct {
+	inputs  []float64
+	query   []float64
+	key     []float64
+	value   []float64
+	weights [][]float64
+	context []float64
+	norm1   []float64
+	hidden  []float64
+	norm2   []float64
+	// Normalized values (before gamma and beta) and standard deviations of the tokens of every norm
+	normalized1 []float64
+	normalized2 []float64
+	deviations1 []float64
+	deviations2 []float64
+}
+
+// transformerGroups are the neurons of every part of the block
+type transformerGroups struct {
+	query, key, value, output, norm1, hidden, feedForward, norm2 []*Neuron
+}
+
+// transformerKind keeps what the last forward of a transformer layer keeps for the backward
+type transformerKind struct {
+	NeuronKind
+	attention *attentionStep
+}
+
+func (layer *Layer) isTransformer() bool {
+	return layer.Type == "transformer" || layer.Type == "positional"
+}
+
+// New checks the shape and creates the neurons of the block (positional layers don't have neurons)
+func (*transformerKind) New(layer *Layer) {
+	if layer.Dimensions < 1 || layer.Inputs%layer.Dimensions != 0 {
+		panic("need inputs of sequence * dimensions")
+	}
+	if layer.Sequence == 0 {
+		layer.Sequence = layer.Inputs / layer.Dimensions
+	}
+	if layer.Sequence*layer.Dimensions != layer.Inputs {
+		panic("need inputs of sequence * dimensions")
+	}
+	layer.Units = layer.Inputs
+
+	if layer.Type == "positional" {
+		layer.Activation = "linear"
+		return
+	}
+
+	if layer.Heads == 0 {
+		layer.Heads = 1
+	}
+	if layer.Dimensions%layer.Heads != 0 {
+		panic("need dimensions divisible by heads")
+	}
+	if layer.Hidden == 0 {
+		layer.Hidden = 4 * layer.Dimensions
+	}
+	if layer.Activation == "" {
+		layer.Activation = "relu"
+	}
+
+	dimensions := layer.Dimensions
+	for _, size := range []struct{ neurons, inputs int }{
+		{4 * dimensions, dimensions}, {dimensions, 1}, {layer.Hidden, dimensions}, {dimensions, layer.Hidden}, {dimensions, 1},
+	} {
+		for n := 0; n < size.neurons; n++ {
+			neuron := NewNeuron(layer, size.inputs)
+			if size.inputs == 1 {
+				neuron.Weights[0], neuron.Bias = 1.0, 0.0
+			}
+			layer.Neurons = append(layer.Neurons, neuron)
+		}
+	}
+}
+
+// Params are the weights and biases of the units, the gamma and beta of the norms are only regularized like biases
+func (*transformerKind) Params(layer *Layer, units []int) []Param {
+	d := layer.Dimensions
+	params := layer.neuronParams(units, true)
+	for p, param := range params {
+		if (param.Unit >= 4*d && param.Unit < 5*d) || param.Unit >= 6*d+layer.Hidden {
+			params[p].Bias = true
+		}
+	}
+	return params
+}
+
+func (kind *transformerKind) State(layer *Layer) interface{} {
+	return kind.attention
+}
+
+func (kind *transformerKind) SetState(layer *Layer, state interface{}) {
+	kind.attention = state.(*attentionStep)
+}
+
+func (kind *transformerKind) Import(layer *Layer, data json.RawMessage) error {
+	kind.NeuronKind.Import(layer, data)
+	layer.Inputs = layer.Sequence * layer.Dimensions
+	layer.Units = layer.Inputs
+	return nil
+}
+
+// Reset starts the norms as identity
+func (kind *transformerKind) Reset(layer *Layer) {
+	kind.NeuronKind.Reset(layer)
+	if layer.Type == "positional" {
+		return
+	}
+	for _, neuron := range layer.groups().norm1 {
+		neuron.Weights[0], neuron.Bias = 1.0, 0.0
+	}
+	for _, neuron := range layer.groups().norm2 {
+		neuron.Weights[0], neuron.Bias = 1.0, 0.0
+	}
+}
+
+// groups splits the neurons of the block
+func (layer *Layer) groups() transformerGroups {
+	d, neurons := layer.Dimensions, layer.Neurons
+	return transformerGroups{
+		query:       neurons[0:d],
+		key:         neurons[d : 2*d],
+		value:       neurons[2*d : 3*d],
+		output:      neurons[3*d : 4*d],
+		norm1:       neurons[4*d : 5*d],
+		hidden:      neurons[5*d : 5*d+layer.Hidden],
+		feedForward: neurons[5*d+layer.Hidden : 6*d+layer.Hidden],
+		norm2:       neurons[6*d+layer.Hidden:],
+	}
+}
+
+// positional adds the sinusoidal encoding: sin(t / 10000^(2i/d)) on even values and cos on odd ones
+func (layer *Layer) positional(inputs []float64, outs []float64) {
+	d := layer.Dimensions
+	for t := 0; t < layer.Sequence; t++ {
+		for i := 0; i < d; i++ {
+			angle := float64(t) / math.Pow(10000.0, float64(i-i%2)/float64(d))
+			if i%2 == 0 {
+				outs[t*d+i] = inputs[t*d+i] + math.Sin(angle)
+			} else {
+				outs[t*d+i] = inputs[t*d+i] + math.Cos(angle)
+			}
+		}
+	}
+}
+
+// Forward process the encoder block
+func (kind *transformerKind) Forward(layer *Layer, inputs []float64, outs []float64, training bool) {
+	if layer.Type == "positional" {
+		layer.positional(inputs, outs)
+		return
+	}
+
+	groups, step := layer.groups(), &attentionStep{inputs: append([]float64(nil), inputs[:layer.Inputs]...)}
+	tokens, d := layer.Sequence, layer.Dimensions
+	size := d / layer.Heads
+	scale := 1.0 / math.Sqrt(float64(size))
+
+	step.query = project(groups.query, step.inputs, nil)
+	step.key = project(groups.key, step.inputs, nil)
+	step.value = project(groups.value, step.inputs, nil)
+
+	// weights of every head and token over all tokens
+	step.weights = make([][]float64, layer.Heads*tokens)
+	step.context = make([]float64, tokens*d)
+	for h := 0; h < layer.Heads; h++ {
+		for t := 0; t < tokens; t++ {
+			weights := make([]float64, tokens)
+			for u := range weights {
+				for c := h * size; c < (h+1)*size; c++ {
+					weights[u] += step.query[t*d+c] * step.key[u*d+c] * scale
+				}
+			}
+			softmax(weights)
+			step.weights[h*tokens+t] = weights
+
+			for u, weight := range weights {
+				for c := h * size; c < (h+1)*size; c++ {
+					step.context[t*d+c] += weight * step.value[u*d+c]
+				}
+			}
+		}
+	}
+
+	attention := project(groups.output, step.context, nil)
+	for i := range attention {
+		attention[i] += step.inputs[i]
+	}
+	step.norm1, step.normalized1, step.deviations1 = tokenNorm(groups.norm1, attention)
+
+	step.hidden = project(groups.hidden, step.norm1, layer.Forward)
+	feedForward := project(groups.feedForward, step.hidden, nil)
+	for i := range feedForward {
+		feedForward[i] += step.norm1[i]
+	}
+	step.norm2, step.normalized2, step.deviations2 = tokenNorm(groups.norm2, feedForward)
+
+	kind.attention = step
+	copy(outs, step.norm2)
+}
+
+// Backward adds the gradients of the block and returns the errors of the inputs
+func (kind *transformerKind) Backward(layer *Layer, errors []float64) []float64 {
+	if layer.Type == "positional" {
+		return append([]float64(nil), errors...)
+	}
+
+	groups, step := layer.groups(), kind.attention
+	tokens, d := layer.Sequence, layer.Dimensions
+	size := d / layer.Heads
+	scale := 1.0 / math.Sqrt(float64(size))
+
+	// second norm, feed-forward and its residual
+	sum2 := tokenNormBackward(groups.norm2, step.normalized2, step.deviations2, errors)
+	hiddenErrors := projectBackward(groups.feedForward, step.hidden, sum2)
+	for i, hidden := range step.hidden {
+		hiddenErrors[i] *= layer.Backward(hidden)
+	}
+	norm1 := projectBackward(groups.hidden, step.norm1, hiddenErrors)
+	for i := range norm1 {
+		norm1[i] += sum2[i]
+	}
+
+	// first norm, attention and its residual
+	sum1 := tokenNormBackward(groups.norm1, step.normalized1, step.deviations1, norm1)
+	context := projectBackward(groups.output, step.context, sum1)
+
+	query, key, value := make([]float64, tokens*d), make([]float64, tokens*d), make([]float64, tokens*d)
+	for h := 0; h < layer.Heads; h++ {
+		for t := 0; t < tokens; t++ {
+			weights := step.weights[h*tokens+t]
+
+			// errors of the weights and then of the scores through the softmax
+			weightErrors := make([]float64, tokens)
+			mean := 0.0
+			for u, weight := range weights {
+				for c := h * size; c < (h+1)*size; c++ {
+					weightErrors[u] += context[t*d+c] * step.value[u*d+c]
+					value[u*d+c] += weight * context[t*d+c]
+				}
+				mean += weight * weightErrors[u]
+			}
+
+			for u, weight := range weights {
+				score := weight * (weightErrors[u] - mean) * scale
+				for c := h * size; c < (h+1)*size; c++ {
+					query[t*d+c] += score * step.key[u*d+c]
+					key[u*d+c] += score * step.query[t*d+c]
+				}
+			}
+		}
+	}
+
+	inputErrors := sum1
+	for _, errors := range [][]float64{
+		projectBackward(groups.query, step.inputs, query),
+		projectBackward(groups.key, step.inputs, key),
+		projectBackward(groups.value, step.inputs, value),
+	} {
+		for i := range inputErrors {
+			inputErrors[i] += errors[i]
+		}
+	}
+
+	return inputErrors
+}
+
+// project multiplies every token of the values by the neurons (plus bias) and applies the activation if any
+func project(neurons []*Neuron, values []float64, forward ForwardFn) []float64 {
+	inputs, units := len(neurons[0].Weights), len(neurons)
+	tokens := len(values) / inputs
+	outs := make([]float64, tokens*units)
+
+	for t := 0; t < tokens; t++ {
+		for n, neuron := range neurons {
+			sum := neuron.Bias
+			for w, weight := range neuron.Weights {
+				sum += values[t*inputs+w] * weight
+			}
+			if forward != nil {
+				sum = forward(sum)
+			}
+			outs[t*units+n] = sum
+		}
+	}
+	return outs
+}
+
+// projectBackward adds the gradients of the neurons of a projection and returns the errors of its values
+func projectBackward(neurons []*Neuron, values []float64, errors []float64) []float64 {
+	inputs, units := len(neurons[0].Weights), len(neurons)
+	valueErrors := make([]float64, len(values))
+
+	for t := 0; t < len(values)/inputs; t++ {
+		for n, neuron := range neurons {
+			delta := errors[t*units+n]
+			for w, weight := range neuron.Weights {
+				valueErrors[t*inputs+w] += weight * delta
+				neuron.Gradients[w] -= values[t*inputs+w] * delta
+			}
+			neuron.Gradients[len(neuron.Weights)] -= delta
+		}
+	}
+	return valueErrors
+}
+
+// tokenNorm applies layernorm to every token with the gamma (weight) and beta (bias) of the neurons
+// It returns the outputs, the normalized values and the standard deviation of every token
+func tokenNorm(neurons []*Neuron, values []float64) ([]float64, []float64, []float64) {
+	d := len(neurons)
+	outs, normalized := make([]float64, len(values)), make([]float64, len(values))
+	deviations := make([]float64, len(values)/d)
+
+	for t := range deviations {
+		mean, variance := meanVariance(values[t*d : (t+1)*d])
+		deviations[t] = math.Sqrt(variance + normEpsilon)
+		for i, neuron := range neurons {
+			normalized[t*d+i] = (values[t*d+i] - mean) / deviations[t]
+			outs[t*d+i] = neuron.Weights[0]*normalized[t*d+i] + neuron.Bias
+		}
+	}
+	return outs, normalized, deviations
+}
+
+// tokenNormBackward adds the gradients of gamma and beta and returns the errors of the values of tokenNorm
+func tokenNormBackward(neurons []*Neuron, normalized []float64, deviations []float64, errors []float64) []float64 {
+	d := len(neurons)
+	valueErrors := make([]float64, len(normalized))
+
+	for t, deviation := range deviations {
+		scaled := make([]float64, d)
+		meanScaled, meanProduct := 0.0, 0.0
+
+		for i, neuron := range neurons {
+			neuron.Gradients[0] -= normalized[t*d+i] * errors[t*d+i]
+			neuron.Gradients[1] -= errors[t*d+i]
+
+			scaled[i] = neuron.Weights[0] * errors[t*d+i]
+			meanScaled += scaled[i] / float64(d)
+			meanProduct += scaled[i] * normalized[t*d+i] / float64(d)
+		}
+
+		for i := range neurons {
+			valueErrors[t*d+i] = (scaled[i] - meanScaled - normalized[t*d+i]*meanProduct) / deviation
+		}
+	}
+	return valueErrors
+}
+
+// softmax of the values in place
+func softmax(values []float64) {
+	max := math.Inf(-1)
+	for _, value := range values {
+		max = math.Max(max, value)
+	}
+
+	sum := 0.0
+	for i, value := range values {
+		values[i] = math.Exp(value - max)
+		sum += values[i]
+	}
+	for i := range values {
+		values[i] /= sum
+	}
+}
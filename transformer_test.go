@@ -0,0 +1,186 @@
+package neural
+
+import (
+	"math"
+	"testing"
+)
+
+// closeTo fails if the outputs are not the expected ones
+func closeTo(t *testing.T, outputs []float64, expected []float64) {
+	t.Helper()
+
+	for i := range expected {
+		if math.Abs(outputs[i]-expected[i]) > 1e-9 {
+			t.Fatalf("outputs %.10f, expected %.10f", outputs, expected)
+		}
+	}
+}
+
+func TestPositional(t *testing.T) {
+	// 2 tokens of 4 zeros: [sin 0, cos 0, sin 0, cos 0], [sin 1, cos 1, sin 1/100, cos 1/100]
+	positional := NewNeural([]*Layer{{Type: "positional", Inputs: 8, Dimensions: 4}})
+	closeTo(t, positional.ThinkRaw(make([]float64, 8)), []float64{0, 1, 0, 1, math.Sin(1), math.Cos(1), math.Sin(0.01), math.Cos(0.01)})
+}
+
+// referenceBlock calculates an encoder block step by step with the params in the order of the neurons
+// (query, key, value, output, first norm, hidden, feed-forward output, second norm; weights and then bias)
+func referenceBlock(params []float64, inputs []float64, dimensions int, heads int, hidden int) []float64 {
+	next := func(rows int, columns int) ([][]float64, []float64) {
+		weights, bias := make([][]float64, rows), make([]float64, rows)
+		for r := range weights {
+			weights[r], bias[r], params = params[:columns], params[columns], params[columns+1:]
+		}
+		return weights, bias
+	}
+	linear := func(weights [][]float64, bias []float64, x []float64) []float64 {
+		y := make([]float64, len(weights))
+		for r := range weights {
+			y[r] = bias[r]
+			for c := range x {
+				y[r] += weights[r][c] * x[c]
+			}
+		}
+		return y
+	}
+	norm := func(gamma [][]float64, beta []float64, x []float64) []float64 {
+		mean, variance := 0.0, 0.0
+		for _, value := range x {
+			mean += value / float64(len(x))
+		}
+		for _, value := range x {
+			variance += (value - mean) * (value - mean) / float64(len(x))
+		}
+		y := make([]float64, len(x))
+		for i := range x {
+			y[i] = gamma[i][0]*(x[i]-mean)/math.Sqrt(variance+1e-5) + beta[i]
+		}
+		return y
+	}
+
+	wq, bq := next(dimensions, dimensions)
+	wk, bk := next(dimensions, dimensions)
+	wv, bv := next(dimensions, dimensions)
+	wo, bo := next(dimensions, dimensions)
+	g1, b1 := next(dimensions, 1)
+	wh, bh := next(hidden, dimensions)
+	wf, bf := next(dimensions, hidden)
+	g2, b2 := next(dimensions, 1)
+
+	tokens := len(inputs) / dimensions
+	x, q, k, v := make([][]float64, tokens), make([][]float64, tokens), make([][]float64, tokens), make([][]float64, tokens)
+	for t := range x {
+		x[t] = inputs[t*dimensions : (t+1)*dimensions]
+		q[t], k[t], v[t] = linear(wq, bq, x[t]), linear(wk, bk, x[t]), linear(wv, bv, x[t])
+	}
+
+	outputs := []float64{}
+	size := dimensions / heads
+	for t := range x {
+		// every head attends over all tokens with its slice of the dimensions
+		context := make([]float64, dimensions)
+		for h := 0; h < heads; h++ {
+			scores, sum := make([]float64, tokens), 0.0
+			for u := range x {
+				dot := 0.0
+				for c := h * size; c < (h+1)*size; c++ {
+					dot += q[t][c] * k[u][c]
+				}
+				scores[u] = math.Exp(dot / math.Sqrt(float64(size)))
+				sum += scores[u]
+			}
+			for u := range x {
+				for c := h * size; c < (h+1)*size; c++ {
+					context[c] += scores[u] / sum * v[u][c]
+				}
+			}
+		}
+
+		attention := linear(wo, bo, context)
+		for i := range attention {
+			attention[i] += x[t][i]
+		}
+		norm1 := norm(g1, b1, attention)
+
+		relu := linear(wh, bh, norm1)
+		for i := range relu {
+			relu[i] = math.Max(relu[i], 0)
+		}
+		feedForward := linear(wf, bf, relu)
+		for i := range feedForward {
+			feedForward[i] += norm1[i]
+		}
+		outputs = append(outputs, norm(g2, b2, feedForward)...)
+	}
+	return outputs
+}
+
+func TestTransformerReference(t *testing.T) {
+	// encoder blocks of 2 and 3 tokens with fixed params (norms far from identity) against the naive calculation
+	for _, shape := range []struct{ inputs, dimensions, heads, hidden int }{{8, 4, 2, 2}, {12, 4, 4, 3}, {12, 6, 3, 5}} {
+		block := NewNeural([]*Layer{{Type: "transformer", Inputs: shape.inputs, Dimensions: shape.dimensions, Heads: shape.heads, Hidden: shape.hidden}})
+		params := make([]float64, block.NumParams())
+		for i := range params {
+			params[i] = float64((i*7)%11-5) / 10.0
+		}
+		block.SetParams(params)
+
+		inputs := make([]float64, shape.inputs)
+		for i := range inputs {
+			inputs[i] = math.Sin(float64(3*i + 1))
+		}
+		closeTo(t, block.ThinkRaw(inputs), referenceBlock(params, inputs, shape.dimensions, shape.heads, shape.hidden))
+	}
+}
+
+func TestGradCheckTransformer(t *testing.T) {
+	// a classifier with positional encoding and two blocks
+	classifier := NewNeural([]*Layer{
+		{Type: "positional", Inputs: 12, Dimensions: 4},
+		{Type: "transformer", Heads: 2, Hidden: 6},
+		{Type: "transformer", Heads: 4, Activation: "tanh"},
+		{Units: 2, Activation: "sigmoid"},
+	})
+	classifier.Seed(1)
+	classifier.Reset()
+
+	inputs := []float64{0.3, -0.8, 0.5, 0.1, -0.4, 0.9, 0.2, 0.7, -0.3, 0.6, -0.5, 0.4}
+	targets := []float64{1, 0}
+	params := classifier.Params()
+	loss := func() float64 {
+		return lossObjective("mse", classifier.forward(inputs, false), targets)
+	}
+
+	classifier.ZeroGrad()
+	classifier.backward(classifier.forward(inputs, false), targets)
+	analytic := classifier.Grads()
+
+	// the gradients of the bias of attention keys are zero (softmax ignores the same shift of all scores),
+	// their finite differences are only the rounding of the loss, so they are compared without scale
+	const epsilon = 1e-5
+	for p := range params {
+		shifted := append([]float64{}, params...)
+		shifted[p] += epsilon
+		classifier.SetParams(shifted)
+		plus := loss()
+
+		shifted[p] -= 2 * epsilon
+		classifier.SetParams(shifted)
+		minus := loss()
+
+		numeric := (plus - minus) / (2 * epsilon)
+		scale := math.Max(math.Max(math.Abs(analytic[p]), math.Abs(numeric)), 1e-8)
+		if relative := math.Abs(analytic[p]-numeric) / scale; relative > gradTolerance && math.Abs(analytic[p]-numeric) > 1e-9 {
+			t.Fatalf("param %v: analytic %v numeric %v", p, analytic[p], numeric)
+		}
+	}
+}
+
+func TestTransformerRoundTrip(t *testing.T) {
+	block := NewNeural([]*Layer{{Type: "transformer", Inputs: 8, Dimensions: 4, Heads: 2}})
+	block.Seed(1)
+	block.Reset()
+	imported := roundTrip(t, block)
+
+	inputs := []float64{0.5, -0.2, 0.1, 0.8, -0.6, 0.3, 0.9, -0.1}
+	closeTo(t, imported.ThinkRaw(inputs), block.ThinkRaw(inputs))
+}
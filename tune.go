@@ -0,0 +1,413 @@
+package neural
+
+import (
+	"math"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// Space is the search space of a tuning, every list has the options to try (empty uses the default)
+type Space struct {
+	// Inputs and outputs of the neural (default is the selected columns of the dataset)
+	Inputs  int
+	Outputs int
+	// Activation of the output layer (default is sigmoid)
+	Output string
+	// Units of the hidden layers, like {{8}, {16, 8}}
+	Hidden     [][]int
+	Activation []string
+	Rate       []float64
+	Momentum   []float64
+}
+
+// Config is a point of the search space
+type Config struct {
+	Inputs     int     `json:"Inputs"`
+	Outputs    int     `json:"Outputs"`
+	Output     string  `json:"Output,omitempty"`
+	Hidden     []int   `json:"Hidden"`
+	Activation string  `json:"Activation,omitempty"`
+	Rate       float64 `json:"Rate,omitempty"`
+	Momentum   float64 `json:"Momentum,omitempty"`
+	Epochs     int     `json:"Epochs"`
+}
+
+// Trial is a config of the leaderboard with its loss on every fold
+type Trial struct {
+	Config Config    `json:"Config"`
+	Loss   float64   `json:"Loss"`
+	Losses []float64 `json:"Losses"`
+}
+
+// Tuning is the config of a hyperparameter search scored with k-fold cross-validation
+type Tuning struct {
+	Space Space
+	// grid (default), random, halving (successive halving) or hyperband
+	Search string
+	// Amount of configs for random and halving (default is 10)
+	Trials int
+	// Epochs of every config, the maximum for halving and hyperband (default is 100)
+	Epochs int
+	// Reduction factor of halving and hyperband, only the best 1/Eta continue with Eta times more epochs (default is 3)
+	Eta int
+	// Amount of folds (default is 5)
+	Folds int
+	// Amount of goroutines training at the same time (default is the amount of CPUs)
+	Workers int
+	// Seed makes the search reproducible, with any amount of workers (default is crypto/rand)
+	Seed int64
+	// Prepare is called with every new neural and its training data (like FitRanges), from several goroutines
+	Prepare func(neural *Neural, train *Dataset)
+	// Score of a trained neural on the test data, lower is better (default is the mse), from several goroutines
+	Score func(neural *Neural, test *Dataset) float64
+}
+
+// Tune searches the best config for the selected columns of a dataset (rows with missing values are skipped)
+// It returns the leaderboard (best first) and the best config trained on the whole dataset
+// Halving and hyperband rank the configs that reached more epochs first
+func Tune(dataset *Dataset, tuning Tuning) ([]Trial, *Neural) {
+	if tuning.Search == "" {
+		tuning.Search = "grid"
+	}
+	if tuning.Trials == 0 {
+		tuning.Trials = 10
+	}
+	if tuning.Epochs == 0 {
+		tuning.Epochs = 100
+	}
+	if tuning.Eta < 2 {
+		tuning.Eta = 3
+	}
+	if tuning.Folds == 0 {
+		tuning.Folds = 5
+	}
+	if tuning.Workers == 0 {
+		tuning.Workers = runtime.NumCPU()
+	}
+	if tuning.Score == nil {
+		tuning.Score = datasetLoss
+	}
+	if tuning.Space.Inputs == 0 {
+		tuning.Space.Inputs = len(dataset.inputColumns())
+	}
+	if tuning.Space.Outputs == 0 {
+		tuning.Space.Outputs = len(dataset.Targets)
+	}
+
+	var random *Random
+	if tuning.Seed != 0 {
+		random = NewRandom(tuning.Seed)
+	}
+
+	shuffled := dataset.DropMissing()
+	shuffled.Shuffle(random)
+	search := &search{tuning: tuning, random: random, folds: shuffled.KFold(tuning.Folds)}
+
+	trials := []Trial{}
+	switch tuning.Search {
+	case "grid":
+		trials = search.run(search.candidates(tuning.Space.grid()), tuning.Epochs)
+	case "random":
+		trials = search.run(search.candidates(search.sample(tuning.Trials)), tuning.Epochs)
+	case "halving":
+		rounds := 1 + int(math.Floor(logBase(float64(tuning.Trials), float64(tuning.Eta))))
+		trials = search.halving(search.candidates(search.sample(tuning.Trials)), rounds)
+	case "hyperband":
+		brackets := int(math.Floor(logBase(float64(tuning.Epochs), float64(tuning.Eta))))
+		for s := brackets; s >= 0; s-- {
+			configs := int(math.Ceil(float64(brackets+1) / float64(s+1) * math.Pow(float64(tuning.Eta), float64(s))))
+			trials = append(trials, search.halving(search.candidates(search.sample(configs)), s+1)...)
+		}
+	default:
+		panic("need a valid search")
+	}
+
+	sort.SliceStable(trials, func(a, b int) bool {
+		if trials[a].Config.Epochs != trials[b].Config.Epochs {
+			return trials[a].Config.Epochs > trials[b].Config.Epochs
+		}
+		return trials[a].Loss < trials[b].Loss
+	})
+
+	if len(trials) == 0 {
+		return trials, nil
+	}
+
+	best := search.train(trials[0].Config, search.seed(), shuffled)
+	return trials, best
+}
+
+// Layers creates the layer definitions of the config
+func (config Config) Layers() []*Layer {
+	layers := []*Layer{}
+
+	for _, units := range append(append([]int{}, config.Hidden...), config.Outputs) {
+		layers = append(layers, &Layer{
+			Units:      units,
+			Activation: config.Activation,
+			Rate:       config.Rate,
+			Momentum:   config.Momentum,
+		})
+	}
+
+	layers[0].Inputs = config.Inputs
+	layers[len(layers)-1].Activation = config.Output
+
+	return layers
+}
+
+// grid is every combination of the options
+func (space Space) grid() []Config {
+	configs := []Config{}
+
+	for _, hidden := range space.hidden() {
+		for _, activation := range space.activations() {
+			for _, rate := range space.rates() {
+				for _, momentum := range space.momentums() {
+					configs = append(configs, space.config(hidden, activation, rate, momentum))
+				}
+			}
+		}
+	}
+
+	return configs
+}
+
+func (space Space) config(hidden []int, activation string, rate float64, momentum float64) Config {
+	return Config{
+		Inputs:     space.Inputs,
+		Outputs:    space.Outputs,
+		Output:     space.Output,
+		Hidden:     hidden,
+		Activation: activation,
+		Rate:       rate,
+		Momentum:   momentum,
+	}
+}
+
+func (space Space) hidden() [][]int {
+	if len(space.Hidden) == 0 {
+		return [][]int{{}}
+	}
+	return space.Hidden
+}
+
+func (space Space) activations() []string {
+	if len(space.Activation) == 0 {
+		return []string{""}
+	}
+	return space.Activation
+}
+
+func (space Space) rates() []float64 {
+	if len(space.Rate) == 0 {
+		return []float64{0.0}
+	}
+	return space.Rate
+}
+
+func (space Space) momentums() []float64 {
+	if len(space.Momentum) == 0 {
+		return []float64{0.0}
+	}
+	return space.Momentum
+}
+
+// search keeps the state shared by the rounds of a tuning
+type search struct {
+	tuning Tuning
+	random *Random
+	folds  []Fold
+}
+
+// sample picks random configs of the space
+func (search *search) sample(amount int) []Config {
+	space := search.tuning.Space
+	configs := make([]Config, amount)
+
+	for i := range configs {
+		hidden, activations, rates, momentums := space.hidden(), space.activations(), space.rates(), space.momentums()
+		configs[i] = space.config(
+			hidden[search.random.Intn(len(hidden))],
+			activations[search.random.Intn(len(activations))],
+			rates[search.random.Intn(len(rates))],
+			momentums[search.random.Intn(len(momentums))],
+		)
+	}
+
+	return configs
+}
+
+// candidate is a config with its neural of every fold, halving continues training the ones that survive a round
+type candidate struct {
+	config  Config
+	seed    int64
+	neurals []*Neural
+}
+
+// candidates of the configs, seeds are decided before running so the results don't depend on the workers
+func (search *search) candidates(configs []Config) []*candidate {
+	candidates := make([]*candidate, len(configs))
+	for c, config := range configs {
+		config.Epochs = 0
+		candidates[c] = &candidate{config: config, seed: search.seed(), neurals: make([]*Neural, len(search.folds))}
+	}
+	return candidates
+}
+
+// halving trains all configs with few epochs and keeps the best 1/Eta for the next round with Eta times more epochs
+// Every config is in the result with the loss of its last round
+func (search *search) halving(candidates []*candidate, rounds int) []Trial {
+	eta := search.tuning.Eta
+	epochs := float64(search.tuning.Epochs) / math.Pow(float64(eta), float64(rounds-1))
+	trials := []Trial{}
+
+	for round := 0; round < rounds && len(candidates) > 0; round++ {
+		results := search.run(candidates, int(math.Max(math.Round(epochs), 1)))
+		order := make([]int, len(results))
+		for c := range order {
+			order[c] = c
+		}
+		sort.SliceStable(order, func(a, b int) bool {
+			return results[order[a]].Loss < results[order[b]].Loss
+		})
+
+		keep := len(results) / eta
+		if round == rounds-1 {
+			keep = 0
+		}
+
+		survivors := []*candidate{}
+		for i, c := range order {
+			if i < keep {
+				survivors = append(survivors, candidates[c])
+			} else {
+				trials = append(trials, results[c])
+			}
+		}
+		candidates = survivors
+
+		epochs *= float64(eta)
+	}
+
+	return trials
+}
+
+// run trains every candidate on every fold up to an amount of epochs using the workers
+func (search *search) run(candidates []*candidate, epochs int) []Trial {
+	trials := make([]Trial, len(candidates))
+	folds := len(search.folds)
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	for w := 0; w < search.tuning.Workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				c, f := job/folds, job%folds
+				candidate, train := candidates[c], search.folds[f].Train
+
+				// the neural of a previous round continues its training
+				if candidate.neurals[f] == nil {
+					seed := candidate.seed
+					if seed != 0 {
+						seed += int64(f)
+					}
+					candidate.neurals[f] = search.neural(candidate.config, seed, train)
+				}
+				neural := candidate.neurals[f]
+				search.learn(neural, train, epochs-candidate.config.Epochs)
+
+				loss := search.tuning.Score(neural, search.folds[f].Test)
+				if neural.Err() != nil || math.IsNaN(loss) {
+					loss = math.Inf(1)
+				}
+				trials[c].Losses[f] = loss
+			}
+		}()
+	}
+
+	for c, candidate := range candidates {
+		trials[c] = Trial{Losses: make([]float64, folds)}
+		trials[c].Config = candidate.config
+		trials[c].Config.Epochs = epochs
+	}
+	for job := 0; job < len(candidates)*folds; job++ {
+		jobs <- job
+	}
+	close(jobs)
+	wg.Wait()
+
+	for c := range trials {
+		candidates[c].config.Epochs = epochs
+		for _, loss := range trials[c].Losses {
+			trials[c].Loss += loss / float64(folds)
+		}
+	}
+
+	return trials
+}
+
+// train creates a neural of the config and learns the dataset for its epochs
+func (search *search) train(config Config, seed int64, dataset *Dataset) *Neural {
+	neural := search.neural(config, seed, dataset)
+	search.learn(neural, dataset, config.Epochs)
+	return neural
+}
+
+// neural creates a neural of the config for the dataset (prepared but not trained)
+func (search *search) neural(config Config, seed int64, dataset *Dataset) *Neural {
+	var random *Random
+	if seed != 0 {
+		random = NewRandom(seed)
+	}
+
+	layers := config.Layers()
+	for _, layer := range layers {
+		layer.Random = random
+	}
+
+	neural := NewNeural(layers)
+	neural.Random = random
+
+	if search.tuning.Prepare != nil {
+		search.tuning.Prepare(neural, dataset)
+	}
+	return neural
+}
+
+// learn trains the neural for more epochs (it stops if the training was aborted)
+func (search *search) learn(neural *Neural, dataset *Dataset, epochs int) {
+	samples := dataset.Samples()
+	for epoch := 0; epoch < epochs && neural.Err() == nil; epoch++ {
+		neural.Learns(samples)
+	}
+}
+
+// seed for a new config (zero means crypto/rand)
+func (search *search) seed() int64 {
+	if search.random == nil {
+		return 0
+	}
+	return int64(search.random.Uint64()>>2) + 1
+}
+
+// datasetLoss is the mse of a dataset
+func datasetLoss(neural *Neural, dataset *Dataset) float64 {
+	loss, count := 0.0, 0
+	for _, sample := range dataset.Samples() {
+		if hasMissing(sample[0]) || hasMissing(sample[1]) {
+			continue
+		}
+		loss += meanSquaredError(neural.Think(sample[0]), sample[1])
+		count++
+	}
+	return loss / float64(count)
+}
+
+func logBase(value float64, base float64) float64 {
+	return math.Log(value) / math.Log(base)
+}
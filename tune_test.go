@@ -0,0 +1,125 @@
+package neural
+
+import (
+	"math"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// xor is a dataset of the xor function repeated, so every fold has samples
+func xor(repeat int) *Dataset {
+	samples := [][][]float64{}
+	for r := 0; r < repeat; r++ {
+		samples = append(samples, [][]float64{{0, 0}, {0}}, [][]float64{{0, 1}, {1}}, [][]float64{{1, 0}, {1}}, [][]float64{{1, 1}, {0}})
+	}
+	return NewDataset(samples)
+}
+
+func TestTuneWorkers(t *testing.T) {
+	for _, search := range []string{"grid", "random", "halving", "hyperband"} {
+		t.Run(search, func(t *testing.T) {
+			tuning := Tuning{
+				Space:  Space{Hidden: [][]int{{2}, {4}}, Rate: []float64{0.1, 0.5}, Activation: []string{"tanh", "sigmoid"}},
+				Search: search,
+				Trials: 6,
+				Epochs: 9,
+				Folds:  3,
+				Seed:   1,
+			}
+
+			tuning.Workers = 1
+			trials, best := Tune(xor(3), tuning)
+			tuning.Workers = 4
+			parallel, parallelBest := Tune(xor(3), tuning)
+
+			if len(trials) == 0 || !reflect.DeepEqual(trials, parallel) {
+				t.Fatalf("trials with one worker %v and with several %v", trials, parallel)
+			}
+			if !reflect.DeepEqual(best.Params(), parallelBest.Params()) {
+				t.Fatal("the best neural depends on the workers")
+			}
+		})
+	}
+}
+
+func TestTuneHalvingContinuesTraining(t *testing.T) {
+	prepared := 0
+	var mutex sync.Mutex
+
+	tuning := Tuning{
+		Space:  Space{Hidden: [][]int{{2}, {3}, {4}}, Rate: []float64{0.1, 0.3, 0.5}},
+		Search: "halving",
+		Trials: 9,
+		Epochs: 9,
+		Eta:    3,
+		Folds:  2,
+		Seed:   1,
+		Prepare: func(neural *Neural, train *Dataset) {
+			mutex.Lock()
+			prepared++
+			mutex.Unlock()
+		},
+	}
+
+	trials, _ := Tune(xor(2), tuning)
+
+	// rounds of 9, 3 and 1 configs with 1, 3 and 9 epochs, only the first round creates neurals (plus the best one)
+	if prepared != 9*2+1 {
+		t.Fatalf("prepared %v neurals, expected %v", prepared, 9*2+1)
+	}
+	epochs := []int{}
+	for _, trial := range trials {
+		epochs = append(epochs, trial.Config.Epochs)
+	}
+	if !reflect.DeepEqual(epochs, []int{9, 3, 3, 1, 1, 1, 1, 1, 1}) {
+		t.Fatalf("epochs of the trials %v", epochs)
+	}
+}
+
+func TestTuneHalvingEpochs(t *testing.T) {
+	// a survivor trained for 1 and then 3 epochs is the same as one trained for 3 epochs at once
+	tuning := Tuning{Space: Space{Inputs: 2, Outputs: 1, Hidden: [][]int{{3}}}, Workers: 2, Score: datasetLoss}
+	dataset := xor(2)
+
+	continued := &search{tuning: tuning, random: NewRandom(5), folds: dataset.KFold(2)}
+	candidates := continued.candidates(continued.tuning.Space.grid())
+	continued.run(candidates, 1)
+	trials := continued.run(candidates, 3)
+
+	once := &search{tuning: tuning, random: NewRandom(5), folds: dataset.KFold(2)}
+	trained := once.run(once.candidates(once.tuning.Space.grid()), 3)
+
+	if !reflect.DeepEqual(trials, trained) {
+		t.Fatalf("losses %v after continuing and %v at once", trials[0].Losses, trained[0].Losses)
+	}
+}
+
+func TestTuneSkipsMissing(t *testing.T) {
+	dataset := xor(3)
+	dataset.Rows[1][0] = math.NaN()
+	dataset.Rows[6][2] = math.NaN()
+
+	tuning := Tuning{
+		Space:   Space{Hidden: [][]int{{2}}},
+		Epochs:  5,
+		Folds:   2,
+		Seed:    1,
+		Workers: 1,
+		Prepare: func(neural *Neural, train *Dataset) {
+			for _, row := range train.Rows {
+				if hasMissing(row) {
+					t.Error("trained with missing values")
+				}
+			}
+		},
+	}
+
+	trials, best := Tune(dataset, tuning)
+	if len(trials) != 1 || math.IsNaN(trials[0].Loss) || math.IsInf(trials[0].Loss, 0) || best.Err() != nil {
+		t.Fatalf("trials %v", trials)
+	}
+	if len(dataset.Rows) != 12 || !math.IsNaN(dataset.Rows[1][0]) {
+		t.Fatal("changed the dataset")
+	}
+}